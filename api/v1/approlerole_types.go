@@ -0,0 +1,148 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AppRoleRoleFinalizer name of the approlerole finalizer
+	AppRoleRoleFinalizer = "approlerole.finalizers.vault.gobins.github.io"
+	//AppRoleRoleFailedState state when failed
+	AppRoleRoleFailedState = "failed"
+	//AppRoleRoleCreatedState state when created
+	AppRoleRoleCreatedState = "created"
+	//AppRoleRoleUpdatedState state when updated
+	AppRoleRoleUpdatedState = "updated"
+)
+
+// AppRoleRoleSpec defines the desired state of AppRoleRole
+type AppRoleRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type approle holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//BindSecretID requires a secret_id to login, defaults to true
+	BindSecretID *bool `json:"bind_secret_id,omitempty"`
+	//SecretIDBoundCIDRs is the list of CIDR blocks allowed to login with a secret_id
+	SecretIDBoundCIDRs []string `json:"secret_id_bound_cidrs,omitempty"`
+	//TokenBoundCIDRs is the list of CIDR blocks allowed to use issued tokens
+	TokenBoundCIDRs []string `json:"token_bound_cidrs,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	//PolicyRefs is the list of Policy objects whose policies are attached to issued tokens
+	PolicyRefs  []string `json:"policy_refs,omitempty"`
+	TokenTTL    string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL string   `json:"token_max_ttl,omitempty"`
+	SecretIDTTL string   `json:"secret_id_ttl,omitempty"`
+	//SecretIDNumUses is the number of logins allowed per secret_id, 0 means unlimited
+	SecretIDNumUses int `json:"secret_id_num_uses,omitempty"`
+	//SecretIDTarget delivers a generated secret_id and the role_id into a Secret
+	SecretIDTarget *AppRoleSecretIDTarget `json:"secret_id_target,omitempty"`
+}
+
+// AppRoleSecretIDTarget defines the Secret receiving the role_id and a generated secret_id
+type AppRoleSecretIDTarget struct {
+	//SecretName is the name of the Secret owned by the AppRoleRole
+	SecretName string `json:"secret_name"`
+	//RotateBefore is how long before the secret_id expiry it is rotated, defaults to a third of secret_id_ttl and is capped at half of it
+	RotateBefore string `json:"rotate_before,omitempty"`
+}
+
+// AppRoleRoleStatus defines the observed state of AppRoleRole
+type AppRoleRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+	//Policies is the resolved list of policies written to the role
+	Policies []string `json:"policies,omitempty"`
+	//MissingPolicyRefs lists the policy refs which do not exist or are not created yet
+	MissingPolicyRefs []string `json:"missing_policy_refs,omitempty"`
+	//RoleID is the role_id of the role
+	RoleID string `json:"role_id,omitempty"`
+	//SecretIDAccessor is the accessor of the secret_id delivered to the target Secret
+	SecretIDAccessor string `json:"secret_id_accessor,omitempty"`
+	//SecretIDExpiry is the expiry of the delivered secret_id, unset when it does not expire
+	SecretIDExpiry *metav1.Time `json:"secret_id_expiry,omitempty"`
+	//SecretIDTTL is the lifetime in seconds Vault granted the delivered secret_id
+	SecretIDTTL int64 `json:"secret_id_ttl,omitempty"`
+	//RetiredSecretIDAccessors lists the accessors of replaced secret_ids which are not revoked yet
+	RetiredSecretIDAccessors []string `json:"retired_secret_id_accessors,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AppRoleRole is the Schema for the approleroles API
+type AppRoleRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AppRoleRoleSpec   `json:"spec,omitempty"`
+	Status *AppRoleRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AppRoleRole) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an approle role has been created
+func (a *AppRoleRole) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (a *AppRoleRole) HasFinalizer(name string) bool {
+	return containsString(a.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (a *AppRoleRole) AddFinalizer(name string) {
+	a.ObjectMeta.Finalizers = append(a.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (a *AppRoleRole) RemoveFinalizer(name string) {
+	a.ObjectMeta.Finalizers = removeString(a.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (a *AppRoleRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AppRoleRoleList contains a list of AppRoleRole
+type AppRoleRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppRoleRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppRoleRole{}, &AppRoleRoleList{})
+}
@@ -0,0 +1,123 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AWSAuthConfigFinalizer name of the awsauthconfig finalizer
+	AWSAuthConfigFinalizer = "awsauthconfig.finalizers.vault.gobins.github.io"
+	//AWSAuthConfigFailedState state when failed
+	AWSAuthConfigFailedState = "failed"
+	//AWSAuthConfigCreatedState state when created
+	AWSAuthConfigCreatedState = "created"
+	//AWSAuthConfigUpdatedState state when updated
+	AWSAuthConfigUpdatedState = "updated"
+)
+
+// AWSAuthConfigSpec defines the desired state of AWSAuthConfig
+type AWSAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type aws to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//AccessKey references the secret key holding the AWS access key, instance credentials are used when unset
+	AccessKey *SecretKeyReference `json:"access_key,omitempty"`
+	//SecretKey references the secret key holding the AWS secret key
+	SecretKey *SecretKeyReference `json:"secret_key,omitempty"`
+	//Endpoint is the URL override for EC2 API calls
+	Endpoint string `json:"endpoint,omitempty"`
+	//IAMEndpoint is the URL override for IAM API calls
+	IAMEndpoint string `json:"iam_endpoint,omitempty"`
+	//STSEndpoint is the URL override for STS API calls
+	STSEndpoint string `json:"sts_endpoint,omitempty"`
+	//STSRegion is the region used for STS API calls
+	STSRegion string `json:"sts_region,omitempty"`
+	//IAMServerIDHeaderValue is the expected value of the X-Vault-AWS-IAM-Server-ID header
+	IAMServerIDHeaderValue string `json:"iam_server_id_header_value,omitempty"`
+}
+
+// AWSAuthConfigStatus defines the observed state of AWSAuthConfig
+type AWSAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the client config was written to
+	Path string `json:"path,omitempty"`
+	//SecretsVersion is the resource version of the referenced credential secrets
+	SecretsVersion string `json:"secrets_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AWSAuthConfig is the Schema for the awsauthconfigs API
+type AWSAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AWSAuthConfigSpec   `json:"spec,omitempty"`
+	Status *AWSAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AWSAuthConfig) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an aws auth config has been written
+func (a *AWSAuthConfig) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (a *AWSAuthConfig) HasFinalizer(name string) bool {
+	return containsString(a.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (a *AWSAuthConfig) AddFinalizer(name string) {
+	a.ObjectMeta.Finalizers = append(a.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (a *AWSAuthConfig) RemoveFinalizer(name string) {
+	a.ObjectMeta.Finalizers = removeString(a.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (a *AWSAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AWSAuthConfigList contains a list of AWSAuthConfig
+type AWSAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AWSAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AWSAuthConfig{}, &AWSAuthConfigList{})
+}
@@ -0,0 +1,126 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AWSAuthRoleFinalizer name of the awsauthrole finalizer
+	AWSAuthRoleFinalizer = "awsauthrole.finalizers.vault.gobins.github.io"
+	//AWSAuthRoleFailedState state when failed
+	AWSAuthRoleFailedState = "failed"
+	//AWSAuthRoleCreatedState state when created
+	AWSAuthRoleCreatedState = "created"
+	//AWSAuthRoleUpdatedState state when updated
+	AWSAuthRoleUpdatedState = "updated"
+)
+
+// AWSAuthRoleSpec defines the desired state of AWSAuthRole
+type AWSAuthRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type aws holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//AuthType is the login method allowed for the role, Vault defaults to iam when unset
+	// +kubebuilder:validation:Enum=iam;ec2
+	AuthType string `json:"auth_type,omitempty"`
+	//BoundIAMPrincipalARNs is the list of IAM principal ARNs allowed to login, iam auth_type only
+	BoundIAMPrincipalARNs []string `json:"bound_iam_principal_arns,omitempty"`
+	//BoundIAMRoleARNs is the list of IAM role ARNs of the instance profile, ec2 auth_type only
+	BoundIAMRoleARNs []string `json:"bound_iam_role_arns,omitempty"`
+	//BoundAccountIDs is the list of AWS account IDs allowed to login, ec2 auth_type only
+	BoundAccountIDs []string `json:"bound_account_ids,omitempty"`
+	//BoundRegions is the list of AWS regions allowed to login, ec2 auth_type only
+	BoundRegions []string `json:"bound_regions,omitempty"`
+	//BoundVPCIDs is the list of VPC IDs allowed to login, ec2 auth_type only
+	BoundVPCIDs []string `json:"bound_vpc_ids,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+}
+
+// AWSAuthRoleStatus defines the observed state of AWSAuthRole
+type AWSAuthRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AWSAuthRole is the Schema for the awsauthroles API
+type AWSAuthRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AWSAuthRoleSpec   `json:"spec,omitempty"`
+	Status *AWSAuthRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AWSAuthRole) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an aws auth role has been created
+func (a *AWSAuthRole) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (a *AWSAuthRole) HasFinalizer(name string) bool {
+	return containsString(a.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (a *AWSAuthRole) AddFinalizer(name string) {
+	a.ObjectMeta.Finalizers = append(a.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (a *AWSAuthRole) RemoveFinalizer(name string) {
+	a.ObjectMeta.Finalizers = removeString(a.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (a *AWSAuthRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AWSAuthRoleList contains a list of AWSAuthRole
+type AWSAuthRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AWSAuthRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AWSAuthRole{}, &AWSAuthRoleList{})
+}
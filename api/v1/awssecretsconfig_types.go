@@ -0,0 +1,102 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AWSSecretsConfigFailedState state when failed
+	AWSSecretsConfigFailedState = "failed"
+	//AWSSecretsConfigCreatedState state when created
+	AWSSecretsConfigCreatedState = "created"
+	//AWSSecretsConfigUpdatedState state when updated
+	AWSSecretsConfigUpdatedState = "updated"
+)
+
+// AWSSecretsConfigSpec defines the desired state of AWSSecretsConfig
+type AWSSecretsConfigSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type aws to configure
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the aws mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//AccessKey references the secret key holding the AWS access key, instance credentials are used when unset
+	AccessKey *SecretKeyReference `json:"access_key,omitempty"`
+	//SecretKey references the secret key holding the AWS secret key
+	SecretKey *SecretKeyReference `json:"secret_key,omitempty"`
+	//Region is the region of the AWS API calls
+	Region string `json:"region,omitempty"`
+	//IAMEndpoint is the URL override for IAM API calls
+	IAMEndpoint string `json:"iam_endpoint,omitempty"`
+	//STSEndpoint is the URL override for STS API calls
+	STSEndpoint string `json:"sts_endpoint,omitempty"`
+}
+
+// AWSSecretsConfigStatus defines the observed state of AWSSecretsConfig
+type AWSSecretsConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//SecretsVersion is the resource version of the referenced credential secrets
+	SecretsVersion string `json:"secrets_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AWSSecretsConfig is the Schema for the awssecretsconfigs API
+type AWSSecretsConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AWSSecretsConfigSpec   `json:"spec,omitempty"`
+	Status *AWSSecretsConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AWSSecretsConfig) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an aws secrets engine config has been written
+func (a *AWSSecretsConfig) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (a *AWSSecretsConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AWSSecretsConfigList contains a list of AWSSecretsConfig
+type AWSSecretsConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AWSSecretsConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AWSSecretsConfig{}, &AWSSecretsConfigList{})
+}
@@ -0,0 +1,276 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AWSSecretsRoleFinalizer name of the awssecretsrole finalizer
+	AWSSecretsRoleFinalizer = "awssecretsrole.finalizers.vault.gobins.github.io"
+	//AWSSecretsRoleFailedState state when failed
+	AWSSecretsRoleFailedState = "failed"
+	//AWSSecretsRoleCreatedState state when created
+	AWSSecretsRoleCreatedState = "created"
+	//AWSSecretsRoleUpdatedState state when updated
+	AWSSecretsRoleUpdatedState = "updated"
+)
+
+// AWSSecretsRoleSpec defines the desired state of AWSSecretsRole
+type AWSSecretsRoleSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type aws holding the role
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the aws mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Name is the role name
+	Name string `json:"name"`
+	//CredentialType is the type of the credentials generated for the role
+	// +kubebuilder:validation:Enum=iam_user;assumed_role;federation_token
+	CredentialType string `json:"credential_type"`
+	//PolicyARNs is the list of managed policy ARNs attached to the credentials
+	PolicyARNs []string `json:"policy_arns,omitempty"`
+	//PolicyDocument is an inline IAM policy attached to the credentials
+	PolicyDocument *IAMPolicyDocument `json:"policy_document,omitempty"`
+	//RoleARNs is the list of role ARNs allowed to be assumed, assumed_role only
+	RoleARNs []string `json:"role_arns,omitempty"`
+	//IAMGroups is the list of IAM groups the user is added to, iam_user only
+	IAMGroups     []string `json:"iam_groups,omitempty"`
+	DefaultSTSTTL string   `json:"default_sts_ttl,omitempty"`
+	MaxSTSTTL     string   `json:"max_sts_ttl,omitempty"`
+}
+
+// Validate returns an error if the role cannot be written
+func (s *AWSSecretsRoleSpec) Validate() error {
+	switch s.CredentialType {
+	case "iam_user", "federation_token":
+		if len(s.RoleARNs) > 0 {
+			return fmt.Errorf("role_arns is only allowed for assumed_role")
+		}
+		if len(s.PolicyARNs) == 0 && s.PolicyDocument == nil && len(s.IAMGroups) == 0 {
+			return fmt.Errorf("one of policy_arns, policy_document or iam_groups is required")
+		}
+	case "assumed_role":
+		if len(s.RoleARNs) == 0 {
+			return fmt.Errorf("role_arns is required for assumed_role")
+		}
+	default:
+		return fmt.Errorf("credential_type must be iam_user, assumed_role or federation_token")
+	}
+	if len(s.IAMGroups) > 0 && s.CredentialType != "iam_user" {
+		return fmt.Errorf("iam_groups is only allowed for iam_user")
+	}
+	if s.PolicyDocument != nil {
+		return s.PolicyDocument.Validate()
+	}
+	return nil
+}
+
+// IAMPolicyDocument is an IAM policy, rendered to the IAM JSON grammar when written to vault
+type IAMPolicyDocument struct {
+	//Version is the policy language version, defaults to 2012-10-17
+	// +kubebuilder:validation:Enum="2012-10-17";"2008-10-17"
+	Version   string               `json:"version,omitempty"`
+	Statement []IAMPolicyStatement `json:"statement"`
+}
+
+// IAMPolicyStatement is a statement of an IAM policy
+type IAMPolicyStatement struct {
+	Sid string `json:"sid,omitempty"`
+	// +kubebuilder:validation:Enum=Allow;Deny
+	Effect      string               `json:"effect"`
+	Action      []string             `json:"action,omitempty"`
+	NotAction   []string             `json:"not_action,omitempty"`
+	Resource    []string             `json:"resource,omitempty"`
+	NotResource []string             `json:"not_resource,omitempty"`
+	Condition   []IAMPolicyCondition `json:"condition,omitempty"`
+}
+
+// IAMPolicyCondition is a condition of an IAM policy statement, such as StringEquals aws:RequestedRegion
+type IAMPolicyCondition struct {
+	Operator string   `json:"operator"`
+	Key      string   `json:"key"`
+	Values   []string `json:"values"`
+}
+
+// Validate returns an error if the policy does not follow the IAM policy grammar
+func (d *IAMPolicyDocument) Validate() error {
+	if d.Version != "" && d.Version != "2012-10-17" && d.Version != "2008-10-17" {
+		return fmt.Errorf("policy_document version must be 2012-10-17 or 2008-10-17")
+	}
+	if len(d.Statement) == 0 {
+		return fmt.Errorf("policy_document requires at least one statement")
+	}
+	sids := map[string]bool{}
+	for i, statement := range d.Statement {
+		if statement.Sid != "" {
+			if sids[statement.Sid] {
+				return fmt.Errorf("policy_document statement %d: duplicate sid %s", i, statement.Sid)
+			}
+			sids[statement.Sid] = true
+		}
+		if err := statement.validate(); err != nil {
+			return fmt.Errorf("policy_document statement %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *IAMPolicyStatement) validate() error {
+	if s.Effect != "Allow" && s.Effect != "Deny" {
+		return fmt.Errorf("effect must be Allow or Deny")
+	}
+	if (len(s.Action) == 0) == (len(s.NotAction) == 0) {
+		return fmt.Errorf("exactly one of action or not_action is required")
+	}
+	if (len(s.Resource) == 0) == (len(s.NotResource) == 0) {
+		return fmt.Errorf("exactly one of resource or not_resource is required")
+	}
+	for _, action := range append(append([]string{}, s.Action...), s.NotAction...) {
+		if action != "*" && !strings.Contains(action, ":") {
+			return fmt.Errorf("action %q must be * or of the form service:action", action)
+		}
+	}
+	for _, resource := range append(append([]string{}, s.Resource...), s.NotResource...) {
+		if resource != "*" && !strings.HasPrefix(resource, "arn:") {
+			return fmt.Errorf("resource %q must be * or an ARN", resource)
+		}
+	}
+	for _, condition := range s.Condition {
+		if condition.Operator == "" || condition.Key == "" || len(condition.Values) == 0 {
+			return fmt.Errorf("condition requires operator, key and values")
+		}
+	}
+	return nil
+}
+
+// Render returns the policy as IAM policy JSON
+func (d *IAMPolicyDocument) Render() (string, error) {
+	if d == nil {
+		return "", nil
+	}
+	version := d.Version
+	if version == "" {
+		version = "2012-10-17"
+	}
+	var statements []map[string]interface{}
+	for _, s := range d.Statement {
+		statement := map[string]interface{}{"Effect": s.Effect}
+		if s.Sid != "" {
+			statement["Sid"] = s.Sid
+		}
+		if len(s.Action) > 0 {
+			statement["Action"] = s.Action
+		}
+		if len(s.NotAction) > 0 {
+			statement["NotAction"] = s.NotAction
+		}
+		if len(s.Resource) > 0 {
+			statement["Resource"] = s.Resource
+		}
+		if len(s.NotResource) > 0 {
+			statement["NotResource"] = s.NotResource
+		}
+		if len(s.Condition) > 0 {
+			conditions := map[string]map[string][]string{}
+			for _, c := range s.Condition {
+				if conditions[c.Operator] == nil {
+					conditions[c.Operator] = map[string][]string{}
+				}
+				conditions[c.Operator][c.Key] = append(conditions[c.Operator][c.Key], c.Values...)
+			}
+			statement["Condition"] = conditions
+		}
+		statements = append(statements, statement)
+	}
+	document, err := json.Marshal(map[string]interface{}{
+		"Version":   version,
+		"Statement": statements,
+	})
+	return string(document), err
+}
+
+// AWSSecretsRoleStatus defines the observed state of AWSSecretsRole
+type AWSSecretsRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the aws mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AWSSecretsRole is the Schema for the awssecretsroles API
+type AWSSecretsRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AWSSecretsRoleSpec   `json:"spec,omitempty"`
+	Status *AWSSecretsRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AWSSecretsRole) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an aws secrets engine role has been created
+func (a *AWSSecretsRole) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (a *AWSSecretsRole) HasFinalizer(name string) bool {
+	return containsString(a.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (a *AWSSecretsRole) AddFinalizer(name string) {
+	a.ObjectMeta.Finalizers = append(a.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (a *AWSSecretsRole) RemoveFinalizer(name string) {
+	a.ObjectMeta.Finalizers = removeString(a.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (a *AWSSecretsRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AWSSecretsRoleList contains a list of AWSSecretsRole
+type AWSSecretsRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AWSSecretsRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AWSSecretsRole{}, &AWSSecretsRoleList{})
+}
@@ -0,0 +1,100 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AzureAuthConfigFailedState state when failed
+	AzureAuthConfigFailedState = "failed"
+	//AzureAuthConfigCreatedState state when created
+	AzureAuthConfigCreatedState = "created"
+	//AzureAuthConfigUpdatedState state when updated
+	AzureAuthConfigUpdatedState = "updated"
+)
+
+// AzureAuthConfigSpec defines the desired state of AzureAuthConfig
+type AzureAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type azure to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//TenantID is the Azure Active Directory tenant ID
+	TenantID string `json:"tenant_id"`
+	//Resource is the resource URL for the application registered in Azure Active Directory
+	Resource string `json:"resource"`
+	//Environment is the Azure cloud environment, defaults to AzurePublicCloud
+	Environment string `json:"environment,omitempty"`
+	//ClientID is the client ID used to verify VM details, managed identities are used when unset
+	ClientID string `json:"client_id,omitempty"`
+	//ClientSecret references the secret key holding the client secret
+	ClientSecret *SecretKeyReference `json:"client_secret,omitempty"`
+}
+
+// AzureAuthConfigStatus defines the observed state of AzureAuthConfig
+type AzureAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//SecretsVersion is the resource version of the referenced credential secrets
+	SecretsVersion string `json:"secrets_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AzureAuthConfig is the Schema for the azureauthconfigs API
+type AzureAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AzureAuthConfigSpec   `json:"spec,omitempty"`
+	Status *AzureAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AzureAuthConfig) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an azure auth config has been written
+func (a *AzureAuthConfig) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (a *AzureAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AzureAuthConfigList contains a list of AzureAuthConfig
+type AzureAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AzureAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AzureAuthConfig{}, &AzureAuthConfigList{})
+}
@@ -0,0 +1,125 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//AzureAuthRoleFinalizer name of the azureauthrole finalizer
+	AzureAuthRoleFinalizer = "azureauthrole.finalizers.vault.gobins.github.io"
+	//AzureAuthRoleFailedState state when failed
+	AzureAuthRoleFailedState = "failed"
+	//AzureAuthRoleCreatedState state when created
+	AzureAuthRoleCreatedState = "created"
+	//AzureAuthRoleUpdatedState state when updated
+	AzureAuthRoleUpdatedState = "updated"
+)
+
+// AzureAuthRoleSpec defines the desired state of AzureAuthRole
+type AzureAuthRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type azure holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//BoundServicePrincipalIDs is the list of service principal IDs allowed to login
+	BoundServicePrincipalIDs []string `json:"bound_service_principal_ids,omitempty"`
+	//BoundGroupIDs is the list of group IDs allowed to login
+	BoundGroupIDs []string `json:"bound_group_ids,omitempty"`
+	//BoundLocations is the list of locations allowed to login
+	BoundLocations []string `json:"bound_locations,omitempty"`
+	//BoundSubscriptionIDs is the list of subscription IDs allowed to login
+	BoundSubscriptionIDs []string `json:"bound_subscription_ids,omitempty"`
+	//BoundResourceGroups is the list of resource groups allowed to login
+	BoundResourceGroups []string `json:"bound_resource_groups,omitempty"`
+	//BoundScaleSets is the list of scale set names allowed to login
+	BoundScaleSets []string `json:"bound_scale_sets,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+}
+
+// AzureAuthRoleStatus defines the observed state of AzureAuthRole
+type AzureAuthRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AzureAuthRole is the Schema for the azureauthroles API
+type AzureAuthRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *AzureAuthRoleSpec   `json:"spec,omitempty"`
+	Status *AzureAuthRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (a *AzureAuthRole) IsBeingDeleted() bool {
+	return !a.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an azure auth role has been created
+func (a *AzureAuthRole) IsCreated() bool {
+	if a.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (a *AzureAuthRole) HasFinalizer(name string) bool {
+	return containsString(a.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (a *AzureAuthRole) AddFinalizer(name string) {
+	a.ObjectMeta.Finalizers = append(a.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (a *AzureAuthRole) RemoveFinalizer(name string) {
+	a.ObjectMeta.Finalizers = removeString(a.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (a *AzureAuthRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(a.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// AzureAuthRoleList contains a list of AzureAuthRole
+type AzureAuthRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AzureAuthRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AzureAuthRole{}, &AzureAuthRoleList{})
+}
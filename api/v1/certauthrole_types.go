@@ -0,0 +1,136 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//CertAuthRoleFinalizer name of the certauthrole finalizer
+	CertAuthRoleFinalizer = "certauthrole.finalizers.vault.gobins.github.io"
+	//CertAuthRoleFailedState state when failed
+	CertAuthRoleFailedState = "failed"
+	//CertAuthRoleCreatedState state when created
+	CertAuthRoleCreatedState = "created"
+	//CertAuthRoleUpdatedState state when updated
+	CertAuthRoleUpdatedState = "updated"
+)
+
+// CertAuthRoleSpec defines the desired state of CertAuthRole
+type CertAuthRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type cert holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the certificate role name
+	Name string `json:"name"`
+	//DisplayName is the name attached to issued tokens
+	DisplayName string `json:"display_name,omitempty"`
+	//Certificate references the PEM encoded trusted certificate
+	Certificate CertificateReference `json:"certificate"`
+	//AllowedCommonNames is the list of common names allowed to login
+	AllowedCommonNames []string `json:"allowed_common_names,omitempty"`
+	//AllowedDNSSANs is the list of DNS SANs allowed to login
+	AllowedDNSSANs []string `json:"allowed_dns_sans,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+}
+
+// CertificateReference selects a key of a Secret or ConfigMap holding a PEM encoded certificate
+type CertificateReference struct {
+	//Kind is the kind of the referenced object
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+	//Name is the name of the referenced object
+	Name string `json:"name"`
+	//Key is the key holding the certificate
+	Key string `json:"key"`
+}
+
+// CertAuthRoleStatus defines the observed state of CertAuthRole
+type CertAuthRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+	//CertificateVersion is the resource version of the object holding the certificate
+	CertificateVersion string `json:"certificate_version,omitempty"`
+	//NotAfter is the earliest expiry of the trusted certificates
+	NotAfter *metav1.Time `json:"not_after,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CertAuthRole is the Schema for the certauthroles API
+type CertAuthRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *CertAuthRoleSpec   `json:"spec,omitempty"`
+	Status *CertAuthRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (c *CertAuthRole) IsBeingDeleted() bool {
+	return !c.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a cert auth role has been created
+func (c *CertAuthRole) IsCreated() bool {
+	if c.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (c *CertAuthRole) HasFinalizer(name string) bool {
+	return containsString(c.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (c *CertAuthRole) AddFinalizer(name string) {
+	c.ObjectMeta.Finalizers = append(c.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (c *CertAuthRole) RemoveFinalizer(name string) {
+	c.ObjectMeta.Finalizers = removeString(c.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (c *CertAuthRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(c.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// CertAuthRoleList contains a list of CertAuthRole
+type CertAuthRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CertAuthRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CertAuthRole{}, &CertAuthRoleList{})
+}
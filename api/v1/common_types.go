@@ -0,0 +1,25 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// SecretKeyReference selects a key of a Secret in the namespace of the referencing object
+type SecretKeyReference struct {
+	//Name is the name of the secret
+	Name string `json:"name"`
+	//Key is the key within the secret data
+	Key string `json:"key"`
+}
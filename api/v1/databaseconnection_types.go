@@ -0,0 +1,135 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//DatabaseConnectionFinalizer name of the databaseconnection finalizer
+	DatabaseConnectionFinalizer = "databaseconnection.finalizers.vault.gobins.github.io"
+	//DatabaseConnectionFailedState state when failed
+	DatabaseConnectionFailedState = "failed"
+	//DatabaseConnectionCreatedState state when created
+	DatabaseConnectionCreatedState = "created"
+	//DatabaseConnectionUpdatedState state when updated
+	DatabaseConnectionUpdatedState = "updated"
+)
+
+// DatabaseConnectionSpec defines the desired state of DatabaseConnection
+type DatabaseConnectionSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type database holding the connection
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the database mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Name is the connection name
+	Name string `json:"name"`
+	//PluginName is the database plugin, such as postgresql-database-plugin
+	PluginName string `json:"plugin_name"`
+	//ConnectionURL is the connection string, with {{username}} and {{password}} templates
+	ConnectionURL string `json:"connection_url"`
+	//Username references the secret key holding the root username
+	Username *SecretKeyReference `json:"username,omitempty"`
+	//Password references the secret key holding the root password
+	Password *SecretKeyReference `json:"password,omitempty"`
+	//AllowedRoles is the list of roles allowed to use the connection
+	AllowedRoles []string `json:"allowed_roles,omitempty"`
+	//PasswordPolicy is the name of the password policy used to generate passwords
+	PasswordPolicy string `json:"password_policy,omitempty"`
+	//VerifyConnection checks the connection when it is written, vault defaults to true
+	VerifyConnection *bool `json:"verify_connection,omitempty"`
+	//RotateRoot rotates the root password once the connection is written, the password in the Secret is no longer valid afterwards
+	RotateRoot bool `json:"rotate_root,omitempty"`
+}
+
+// DatabaseConnectionStatus defines the observed state of DatabaseConnection
+type DatabaseConnectionStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the database mount path the connection was written to
+	Path string `json:"path,omitempty"`
+	//SecretsHash is an HMAC of the referenced credential values keyed by SecretsSalt
+	SecretsHash string `json:"secrets_hash,omitempty"`
+	//SecretsSalt is the random key of SecretsHash, generated once per object
+	SecretsSalt string `json:"secrets_salt,omitempty"`
+	//LastRootRotation is the time the root password was last rotated
+	LastRootRotation *metav1.Time `json:"last_root_rotation,omitempty"`
+	//RootRotatedHash is the secrets hash whose password was rotated by vault
+	RootRotatedHash string `json:"root_rotated_hash,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseConnection is the Schema for the databaseconnections API
+type DatabaseConnection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *DatabaseConnectionSpec   `json:"spec,omitempty"`
+	Status *DatabaseConnectionStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (d *DatabaseConnection) IsBeingDeleted() bool {
+	return !d.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a database connection has been created
+func (d *DatabaseConnection) IsCreated() bool {
+	if d.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (d *DatabaseConnection) HasFinalizer(name string) bool {
+	return containsString(d.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (d *DatabaseConnection) AddFinalizer(name string) {
+	d.ObjectMeta.Finalizers = append(d.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (d *DatabaseConnection) RemoveFinalizer(name string) {
+	d.ObjectMeta.Finalizers = removeString(d.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (d *DatabaseConnection) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(d.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseConnectionList contains a list of DatabaseConnection
+type DatabaseConnectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseConnection `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseConnection{}, &DatabaseConnectionList{})
+}
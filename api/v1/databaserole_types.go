@@ -0,0 +1,119 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//DatabaseRoleFinalizer name of the databaserole finalizer
+	DatabaseRoleFinalizer = "databaserole.finalizers.vault.gobins.github.io"
+	//DatabaseRoleFailedState state when failed
+	DatabaseRoleFailedState = "failed"
+	//DatabaseRoleCreatedState state when created
+	DatabaseRoleCreatedState = "created"
+	//DatabaseRoleUpdatedState state when updated
+	DatabaseRoleUpdatedState = "updated"
+)
+
+// DatabaseRoleSpec defines the desired state of DatabaseRole
+type DatabaseRoleSpec struct {
+	//DatabaseConnectionRef is the name of the DatabaseConnection used by the role
+	DatabaseConnectionRef string `json:"databaseconnection_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//CreationStatements are the statements creating the user
+	CreationStatements []string `json:"creation_statements"`
+	//RevocationStatements are the statements dropping the user
+	RevocationStatements []string `json:"revocation_statements,omitempty"`
+	//RollbackStatements are the statements run when creating the user fails
+	RollbackStatements []string `json:"rollback_statements,omitempty"`
+	//RenewStatements are the statements run when the lease is renewed
+	RenewStatements []string `json:"renew_statements,omitempty"`
+	DefaultTTL      string   `json:"default_ttl,omitempty"`
+	MaxTTL          string   `json:"max_ttl,omitempty"`
+}
+
+// DatabaseRoleStatus defines the observed state of DatabaseRole
+type DatabaseRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the database mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseRole is the Schema for the databaseroles API
+type DatabaseRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *DatabaseRoleSpec   `json:"spec,omitempty"`
+	Status *DatabaseRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (d *DatabaseRole) IsBeingDeleted() bool {
+	return !d.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a database role has been created
+func (d *DatabaseRole) IsCreated() bool {
+	if d.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (d *DatabaseRole) HasFinalizer(name string) bool {
+	return containsString(d.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (d *DatabaseRole) AddFinalizer(name string) {
+	d.ObjectMeta.Finalizers = append(d.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (d *DatabaseRole) RemoveFinalizer(name string) {
+	d.ObjectMeta.Finalizers = removeString(d.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (d *DatabaseRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(d.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseRoleList contains a list of DatabaseRole
+type DatabaseRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseRole{}, &DatabaseRoleList{})
+}
@@ -0,0 +1,125 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//DatabaseStaticRoleFinalizer name of the databasestaticrole finalizer
+	DatabaseStaticRoleFinalizer = "databasestaticrole.finalizers.vault.gobins.github.io"
+	//DatabaseStaticRoleFailedState state when failed
+	DatabaseStaticRoleFailedState = "failed"
+	//DatabaseStaticRoleCreatedState state when created
+	DatabaseStaticRoleCreatedState = "created"
+	//DatabaseStaticRoleUpdatedState state when updated
+	DatabaseStaticRoleUpdatedState = "updated"
+)
+
+// DatabaseStaticRoleSpec defines the desired state of DatabaseStaticRole
+type DatabaseStaticRoleSpec struct {
+	//DatabaseConnectionRef is the name of the DatabaseConnection used by the role
+	DatabaseConnectionRef string `json:"databaseconnection_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//Username is the existing database user whose password is rotated
+	Username string `json:"username"`
+	//RotationPeriod is the period after which vault rotates the password
+	RotationPeriod string `json:"rotation_period"`
+	//RotationStatements are the statements changing the password, the plugin default is used when unset
+	RotationStatements []string `json:"rotation_statements,omitempty"`
+	//SecretName is the name of a Secret the credentials are synced to, credentials are not synced when unset
+	SecretName string `json:"secret_name,omitempty"`
+}
+
+// DatabaseStaticRoleStatus defines the observed state of DatabaseStaticRole
+type DatabaseStaticRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the database mount path the role was written to
+	Path string `json:"path,omitempty"`
+	//SecretName is the name of the Secret written
+	SecretName string `json:"secret_name,omitempty"`
+	//LastVaultRotation is the time vault last rotated the password
+	LastVaultRotation string `json:"last_vault_rotation,omitempty"`
+	//LastSynced is the time the credentials were last written to the Secret
+	LastSynced *metav1.Time `json:"last_synced,omitempty"`
+	//NextRotation is the time vault rotates the password next
+	NextRotation *metav1.Time `json:"next_rotation,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseStaticRole is the Schema for the databasestaticroles API
+type DatabaseStaticRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *DatabaseStaticRoleSpec   `json:"spec,omitempty"`
+	Status *DatabaseStaticRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (d *DatabaseStaticRole) IsBeingDeleted() bool {
+	return !d.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a database static role has been created
+func (d *DatabaseStaticRole) IsCreated() bool {
+	if d.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (d *DatabaseStaticRole) HasFinalizer(name string) bool {
+	return containsString(d.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (d *DatabaseStaticRole) AddFinalizer(name string) {
+	d.ObjectMeta.Finalizers = append(d.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (d *DatabaseStaticRole) RemoveFinalizer(name string) {
+	d.ObjectMeta.Finalizers = removeString(d.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (d *DatabaseStaticRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(d.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// DatabaseStaticRoleList contains a list of DatabaseStaticRole
+type DatabaseStaticRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatabaseStaticRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatabaseStaticRole{}, &DatabaseStaticRoleList{})
+}
@@ -0,0 +1,177 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//DynamicSecretFinalizer name of the dynamicsecret finalizer
+	DynamicSecretFinalizer = "dynamicsecret.finalizers.vault.gobins.github.io"
+	//DynamicSecretFailedState state when failed
+	DynamicSecretFailedState = "failed"
+	//DynamicSecretCreatedState state when created
+	DynamicSecretCreatedState = "created"
+	//DynamicSecretRenewedState state when the lease was renewed
+	DynamicSecretRenewedState = "renewed"
+	//DynamicSecretRotatedState state when fresh credentials were fetched
+	DynamicSecretRotatedState = "rotated"
+)
+
+// DynamicSecretSpec defines the desired state of DynamicSecret
+type DynamicSecretSpec struct {
+	//Path is the dynamic credentials path, such as database/creds/<role> or aws/creds/<role>
+	Path string `json:"path"`
+	//Parameters are written to the path when set, otherwise the path is read
+	Parameters map[string]string `json:"parameters,omitempty"`
+	//SecretName is the name of the created Secret, defaults to the object name
+	SecretName string `json:"secret_name,omitempty"`
+	//Keys maps the Secret keys to response keys or templates, all response keys are copied when empty
+	Keys []VaultSecretKey `json:"keys,omitempty"`
+	//RotateBefore is how long before the lease can no longer be renewed the credentials are rotated, defaults to a third of the lease duration and is capped at half of it
+	RotateBefore string `json:"rotate_before,omitempty"`
+	//GracePeriod is how long the previous lease is kept after a rotation before it is revoked, defaults to 5m
+	GracePeriod string `json:"grace_period,omitempty"`
+	//RolloutTargets are the workloads restarted when the credentials are rotated
+	RolloutTargets []RolloutTarget `json:"rollout_targets,omitempty"`
+}
+
+// RolloutTarget references a workload restarted on credential rotation
+type RolloutTarget struct {
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// Validate returns an error if the spec cannot be synced
+func (s *DynamicSecretSpec) Validate() error {
+	if s.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if s.RotateBefore != "" {
+		if _, err := time.ParseDuration(s.RotateBefore); err != nil {
+			return fmt.Errorf("invalid rotate_before: %v", err)
+		}
+	}
+	if s.GracePeriod != "" {
+		if _, err := time.ParseDuration(s.GracePeriod); err != nil {
+			return fmt.Errorf("invalid grace_period: %v", err)
+		}
+	}
+	for _, target := range s.RolloutTargets {
+		if target.Kind != "Deployment" && target.Kind != "StatefulSet" {
+			return fmt.Errorf("invalid rollout target kind %s", target.Kind)
+		}
+	}
+	return validateSecretKeys(s.Keys)
+}
+
+// DynamicSecretStatus defines the observed state of DynamicSecret
+type DynamicSecretStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//SecretName is the name of the Secret written
+	SecretName string `json:"secret_name,omitempty"`
+	//LeaseID is the lease of the credentials in the Secret
+	LeaseID string `json:"lease_id,omitempty"`
+	//LeaseDuration is the lease duration in seconds granted when the credentials were fetched
+	LeaseDuration int64 `json:"lease_duration,omitempty"`
+	//LeaseExpiry is the time the current lease expires unless renewed
+	LeaseExpiry *metav1.Time `json:"lease_expiry,omitempty"`
+	Renewable   bool         `json:"renewable,omitempty"`
+	//LastRotated is the time fresh credentials were last written
+	LastRotated *metav1.Time `json:"last_rotated,omitempty"`
+	//PendingRevocations are the previous leases waiting for their grace period to end
+	PendingRevocations []LeaseRevocation `json:"pending_revocations,omitempty"`
+}
+
+// LeaseRevocation is a lease to revoke at a given time
+type LeaseRevocation struct {
+	LeaseID  string      `json:"lease_id"`
+	RevokeAt metav1.Time `json:"revoke_at"`
+}
+
+// +kubebuilder:object:root=true
+
+// DynamicSecret is the Schema for the dynamicsecrets API
+type DynamicSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *DynamicSecretSpec   `json:"spec,omitempty"`
+	Status *DynamicSecretStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (d *DynamicSecret) IsBeingDeleted() bool {
+	return !d.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if credentials have been written
+func (d *DynamicSecret) IsCreated() bool {
+	if d.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetSecretName returns the name of the Secret to write
+func (d *DynamicSecret) GetSecretName() string {
+	if d.Spec.SecretName != "" {
+		return d.Spec.SecretName
+	}
+	return d.GetName()
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (d *DynamicSecret) HasFinalizer(name string) bool {
+	return containsString(d.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (d *DynamicSecret) AddFinalizer(name string) {
+	d.ObjectMeta.Finalizers = append(d.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (d *DynamicSecret) RemoveFinalizer(name string) {
+	d.ObjectMeta.Finalizers = removeString(d.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (d *DynamicSecret) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(d.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// DynamicSecretList contains a list of DynamicSecret
+type DynamicSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DynamicSecret `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DynamicSecret{}, &DynamicSecretList{})
+}
@@ -0,0 +1,98 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//GCPAuthConfigFailedState state when failed
+	GCPAuthConfigFailedState = "failed"
+	//GCPAuthConfigCreatedState state when created
+	GCPAuthConfigCreatedState = "created"
+	//GCPAuthConfigUpdatedState state when updated
+	GCPAuthConfigUpdatedState = "updated"
+)
+
+// GCPAuthConfigSpec defines the desired state of GCPAuthConfig
+type GCPAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type gcp to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//Credentials references the secret key holding the service account JSON key, application default credentials are used when unset
+	Credentials *SecretKeyReference `json:"credentials,omitempty"`
+	//IAMAlias is the entity alias source for iam logins
+	// +kubebuilder:validation:Enum=role_id;unique_id
+	IAMAlias string `json:"iam_alias,omitempty"`
+	//GCEAlias is the entity alias source for gce logins
+	// +kubebuilder:validation:Enum=role_id;instance_id
+	GCEAlias string `json:"gce_alias,omitempty"`
+}
+
+// GCPAuthConfigStatus defines the observed state of GCPAuthConfig
+type GCPAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//SecretsVersion is the resource version of the referenced credential secrets
+	SecretsVersion string `json:"secrets_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GCPAuthConfig is the Schema for the gcpauthconfigs API
+type GCPAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *GCPAuthConfigSpec   `json:"spec,omitempty"`
+	Status *GCPAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (g *GCPAuthConfig) IsBeingDeleted() bool {
+	return !g.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a gcp auth config has been written
+func (g *GCPAuthConfig) IsCreated() bool {
+	if g.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (g *GCPAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(g.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// GCPAuthConfigList contains a list of GCPAuthConfig
+type GCPAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCPAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GCPAuthConfig{}, &GCPAuthConfigList{})
+}
@@ -0,0 +1,126 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//GCPAuthRoleFinalizer name of the gcpauthrole finalizer
+	GCPAuthRoleFinalizer = "gcpauthrole.finalizers.vault.gobins.github.io"
+	//GCPAuthRoleFailedState state when failed
+	GCPAuthRoleFailedState = "failed"
+	//GCPAuthRoleCreatedState state when created
+	GCPAuthRoleCreatedState = "created"
+	//GCPAuthRoleUpdatedState state when updated
+	GCPAuthRoleUpdatedState = "updated"
+)
+
+// GCPAuthRoleSpec defines the desired state of GCPAuthRole
+type GCPAuthRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type gcp holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//Type is the login method allowed for the role
+	// +kubebuilder:validation:Enum=iam;gce
+	Type string `json:"type"`
+	//BoundServiceAccounts is the list of service account emails or IDs allowed to login
+	BoundServiceAccounts []string `json:"bound_service_accounts,omitempty"`
+	//BoundProjects is the list of GCP projects allowed to login
+	BoundProjects []string `json:"bound_projects,omitempty"`
+	//BoundZones is the list of zones a GCE instance must belong to, gce type only
+	BoundZones []string `json:"bound_zones,omitempty"`
+	//BoundRegions is the list of regions a GCE instance must belong to, gce type only
+	BoundRegions []string `json:"bound_regions,omitempty"`
+	//BoundLabels is the list of key:value labels a GCE instance must have, gce type only
+	BoundLabels []string `json:"bound_labels,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+}
+
+// GCPAuthRoleStatus defines the observed state of GCPAuthRole
+type GCPAuthRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GCPAuthRole is the Schema for the gcpauthroles API
+type GCPAuthRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *GCPAuthRoleSpec   `json:"spec,omitempty"`
+	Status *GCPAuthRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (g *GCPAuthRole) IsBeingDeleted() bool {
+	return !g.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a gcp auth role has been created
+func (g *GCPAuthRole) IsCreated() bool {
+	if g.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (g *GCPAuthRole) HasFinalizer(name string) bool {
+	return containsString(g.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (g *GCPAuthRole) AddFinalizer(name string) {
+	g.ObjectMeta.Finalizers = append(g.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (g *GCPAuthRole) RemoveFinalizer(name string) {
+	g.ObjectMeta.Finalizers = removeString(g.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (g *GCPAuthRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(g.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// GCPAuthRoleList contains a list of GCPAuthRole
+type GCPAuthRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCPAuthRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GCPAuthRole{}, &GCPAuthRoleList{})
+}
@@ -0,0 +1,94 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//GitHubAuthConfigFailedState state when failed
+	GitHubAuthConfigFailedState = "failed"
+	//GitHubAuthConfigCreatedState state when created
+	GitHubAuthConfigCreatedState = "created"
+	//GitHubAuthConfigUpdatedState state when updated
+	GitHubAuthConfigUpdatedState = "updated"
+)
+
+// GitHubAuthConfigSpec defines the desired state of GitHubAuthConfig
+type GitHubAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type github to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//Organization is the GitHub organization users must be part of
+	Organization string `json:"organization"`
+	//BaseURL is the API endpoint of a GitHub Enterprise instance, defaults to github.com
+	BaseURL     string `json:"base_url,omitempty"`
+	TokenTTL    string `json:"token_ttl,omitempty"`
+	TokenMaxTTL string `json:"token_max_ttl,omitempty"`
+}
+
+// GitHubAuthConfigStatus defines the observed state of GitHubAuthConfig
+type GitHubAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GitHubAuthConfig is the Schema for the githubauthconfigs API
+type GitHubAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *GitHubAuthConfigSpec   `json:"spec,omitempty"`
+	Status *GitHubAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (g *GitHubAuthConfig) IsBeingDeleted() bool {
+	return !g.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a github auth config has been written
+func (g *GitHubAuthConfig) IsCreated() bool {
+	if g.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (g *GitHubAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(g.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// GitHubAuthConfigList contains a list of GitHubAuthConfig
+type GitHubAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GitHubAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GitHubAuthConfig{}, &GitHubAuthConfigList{})
+}
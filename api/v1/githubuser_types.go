@@ -0,0 +1,117 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//GitHubUserFinalizer name of the githubuser finalizer
+	GitHubUserFinalizer = "githubuser.finalizers.vault.gobins.github.io"
+	//GitHubUserFailedState state when failed
+	GitHubUserFailedState = "failed"
+	//GitHubUserCreatedState state when created
+	GitHubUserCreatedState = "created"
+	//GitHubUserUpdatedState state when updated
+	GitHubUserUpdatedState = "updated"
+)
+
+// GitHubUserSpec defines the desired state of GitHubUser
+type GitHubUserSpec struct {
+	//SysAuthRef is the name of the SysAuth of type github holding the mapping
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the GitHub username
+	Name string `json:"name"`
+	//Policies is the list of vault policy names mapped to the user
+	Policies []string `json:"policies,omitempty"`
+	//PolicyRefs is the list of Policy objects whose policies are mapped to the user
+	PolicyRefs []string `json:"policy_refs,omitempty"`
+}
+
+// GitHubUserStatus defines the observed state of GitHubUser
+type GitHubUserStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the mapping was written to
+	Path string `json:"path,omitempty"`
+	//Policies is the resolved list of policies written to the mapping
+	Policies []string `json:"policies,omitempty"`
+	//MissingPolicyRefs lists the policy refs which do not exist or are not created yet
+	MissingPolicyRefs []string `json:"missing_policy_refs,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GitHubUser is the Schema for the githubusers API
+type GitHubUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *GitHubUserSpec   `json:"spec,omitempty"`
+	Status *GitHubUserStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (g *GitHubUser) IsBeingDeleted() bool {
+	return !g.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a github user mapping has been created
+func (g *GitHubUser) IsCreated() bool {
+	if g.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (g *GitHubUser) HasFinalizer(name string) bool {
+	return containsString(g.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (g *GitHubUser) AddFinalizer(name string) {
+	g.ObjectMeta.Finalizers = append(g.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (g *GitHubUser) RemoveFinalizer(name string) {
+	g.ObjectMeta.Finalizers = removeString(g.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (g *GitHubUser) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(g.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// GitHubUserList contains a list of GitHubUser
+type GitHubUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GitHubUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GitHubUser{}, &GitHubUserList{})
+}
@@ -0,0 +1,151 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//IdentityEntityFinalizer name of the identityentity finalizer
+	IdentityEntityFinalizer = "identityentity.finalizers.vault.gobins.github.io"
+	//IdentityEntityFailedState state when failed
+	IdentityEntityFailedState = "failed"
+	//IdentityEntityCreatedState state when created
+	IdentityEntityCreatedState = "created"
+	//IdentityEntityUpdatedState state when updated
+	IdentityEntityUpdatedState = "updated"
+)
+
+// IdentityEntitySpec defines the desired state of IdentityEntity
+type IdentityEntitySpec struct {
+	//Name is the entity name, the entity is looked up by name so an existing entity is adopted
+	Name string `json:"name"`
+	//Policies is the list of vault policy names attached to the entity
+	Policies []string `json:"policies,omitempty"`
+	//Metadata are key value pairs attached to the entity
+	Metadata map[string]string `json:"metadata,omitempty"`
+	//Disabled prevents tokens of the entity from being used
+	Disabled bool `json:"disabled,omitempty"`
+	//Aliases binds the entity to the identity of a login on auth mounts
+	Aliases []IdentityEntityAlias `json:"aliases,omitempty"`
+}
+
+// Validate returns an error if the entity cannot be written
+func (s *IdentityEntitySpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	refs := map[string]bool{}
+	for _, alias := range s.Aliases {
+		if alias.Name == "" || alias.SysAuthRef == "" {
+			return fmt.Errorf("aliases require name and sysauth_ref")
+		}
+		if refs[alias.SysAuthRef] {
+			return fmt.Errorf("an entity can only have one alias for sysauth %s", alias.SysAuthRef)
+		}
+		refs[alias.SysAuthRef] = true
+	}
+	return nil
+}
+
+// IdentityEntityAlias is an alias of the entity on the auth mount of a SysAuth
+type IdentityEntityAlias struct {
+	//Name is the name of the login on the auth mount, such as the user name or role id
+	Name string `json:"name"`
+	//SysAuthRef is the name of the SysAuth holding the auth mount
+	SysAuthRef string `json:"sysauth_ref"`
+}
+
+// IdentityEntityAliasStatus is an alias created by the controller
+type IdentityEntityAliasStatus struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	SysAuthRef    string `json:"sysauth_ref"`
+	MountAccessor string `json:"mount_accessor"`
+}
+
+// IdentityEntityStatus defines the observed state of IdentityEntity
+type IdentityEntityStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//EntityID is the vault ID of the entity
+	EntityID string `json:"entity_id,omitempty"`
+	//Aliases is the list of aliases created for the entity
+	Aliases []IdentityEntityAliasStatus `json:"aliases,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IdentityEntity is the Schema for the identityentities API
+type IdentityEntity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *IdentityEntitySpec   `json:"spec,omitempty"`
+	Status *IdentityEntityStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (i *IdentityEntity) IsBeingDeleted() bool {
+	return !i.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an identity entity has been created
+func (i *IdentityEntity) IsCreated() bool {
+	if i.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (i *IdentityEntity) HasFinalizer(name string) bool {
+	return containsString(i.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (i *IdentityEntity) AddFinalizer(name string) {
+	i.ObjectMeta.Finalizers = append(i.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (i *IdentityEntity) RemoveFinalizer(name string) {
+	i.ObjectMeta.Finalizers = removeString(i.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (i *IdentityEntity) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(i.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// IdentityEntityList contains a list of IdentityEntity
+type IdentityEntityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IdentityEntity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&IdentityEntity{}, &IdentityEntityList{})
+}
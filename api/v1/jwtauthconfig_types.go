@@ -0,0 +1,141 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//JWTAuthConfigFailedState state when failed
+	JWTAuthConfigFailedState = "failed"
+	//JWTAuthConfigCreatedState state when created
+	JWTAuthConfigCreatedState = "created"
+	//JWTAuthConfigUpdatedState state when updated
+	JWTAuthConfigUpdatedState = "updated"
+)
+
+// JWTAuthConfigSpec defines the desired state of JWTAuthConfig
+type JWTAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type jwt or oidc to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//OIDCDiscoveryURL is the OIDC discovery URL, without the .well-known path
+	OIDCDiscoveryURL string `json:"oidc_discovery_url,omitempty"`
+	//OIDCDiscoveryCAPEM is the PEM encoded CA used to talk to the discovery URL
+	OIDCDiscoveryCAPEM string `json:"oidc_discovery_ca_pem,omitempty"`
+	//OIDCClientID is the OAuth client ID used by the oidc login flow
+	OIDCClientID string `json:"oidc_client_id,omitempty"`
+	//OIDCClientSecret references the secret key holding the OAuth client secret
+	OIDCClientSecret *SecretKeyReference `json:"oidc_client_secret,omitempty"`
+	//JWKSURL is the JWKS URL used to verify JWT signatures
+	JWKSURL string `json:"jwks_url,omitempty"`
+	//JWKSCAPEM is the PEM encoded CA used to talk to the JWKS URL
+	JWKSCAPEM string `json:"jwks_ca_pem,omitempty"`
+	//JWTValidationPubKeys is the list of PEM encoded public keys used to verify JWT signatures
+	JWTValidationPubKeys []string `json:"jwt_validation_pubkeys,omitempty"`
+	//BoundIssuer is the value to match against the iss claim
+	BoundIssuer string `json:"bound_issuer,omitempty"`
+	//DefaultRole is the role used when none is given at login
+	DefaultRole string `json:"default_role,omitempty"`
+}
+
+// Validate returns an error if the spec cannot be written to vault
+func (s *JWTAuthConfigSpec) Validate() error {
+	if s.SysAuthRef == "" {
+		return fmt.Errorf("sysauth_ref is required")
+	}
+	sources := 0
+	for _, set := range []bool{s.OIDCDiscoveryURL != "", s.JWKSURL != "", len(s.JWTValidationPubKeys) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of oidc_discovery_url, jwks_url or jwt_validation_pubkeys is required")
+	}
+	if s.OIDCDiscoveryURL != "" {
+		if err := validateURL(s.OIDCDiscoveryURL); err != nil {
+			return fmt.Errorf("invalid oidc_discovery_url: %v", err)
+		}
+	}
+	if s.JWKSURL != "" {
+		if err := validateURL(s.JWKSURL); err != nil {
+			return fmt.Errorf("invalid jwks_url: %v", err)
+		}
+	}
+	if s.OIDCClientID != "" && s.OIDCDiscoveryURL == "" {
+		return fmt.Errorf("oidc_client_id requires oidc_discovery_url")
+	}
+	if s.OIDCClientSecret != nil && s.OIDCClientID == "" {
+		return fmt.Errorf("oidc_client_secret requires oidc_client_id")
+	}
+	return nil
+}
+
+// JWTAuthConfigStatus defines the observed state of JWTAuthConfig
+type JWTAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//OIDCClientSecretVersion is the resource version of the OIDC client secret
+	OIDCClientSecretVersion string `json:"oidc_client_secret_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// JWTAuthConfig is the Schema for the jwtauthconfigs API
+type JWTAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *JWTAuthConfigSpec   `json:"spec,omitempty"`
+	Status *JWTAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (j *JWTAuthConfig) IsBeingDeleted() bool {
+	return !j.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a jwt auth config has been written
+func (j *JWTAuthConfig) IsCreated() bool {
+	if j.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (j *JWTAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(j.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// JWTAuthConfigList contains a list of JWTAuthConfig
+type JWTAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JWTAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JWTAuthConfig{}, &JWTAuthConfigList{})
+}
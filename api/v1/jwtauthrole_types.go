@@ -0,0 +1,163 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//JWTAuthRoleFinalizer name of the jwtauthrole finalizer
+	JWTAuthRoleFinalizer = "jwtauthrole.finalizers.vault.gobins.github.io"
+	//JWTAuthRoleFailedState state when failed
+	JWTAuthRoleFailedState = "failed"
+	//JWTAuthRoleCreatedState state when created
+	JWTAuthRoleCreatedState = "created"
+	//JWTAuthRoleUpdatedState state when updated
+	JWTAuthRoleUpdatedState = "updated"
+)
+
+// JWTAuthRoleSpec defines the desired state of JWTAuthRole
+type JWTAuthRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type jwt or oidc holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//RoleType is either jwt or oidc
+	// +kubebuilder:validation:Enum=jwt;oidc
+	RoleType string `json:"role_type,omitempty"`
+	//BoundAudiences is the list of aud claims to match
+	BoundAudiences []string `json:"bound_audiences,omitempty"`
+	//BoundSubject is the sub claim to match
+	BoundSubject string `json:"bound_subject,omitempty"`
+	//BoundClaims maps claims to the values they must match
+	BoundClaims map[string]string `json:"bound_claims,omitempty"`
+	//BoundClaimsType is either string or glob
+	// +kubebuilder:validation:Enum=string;glob
+	BoundClaimsType string `json:"bound_claims_type,omitempty"`
+	//ClaimMappings maps claims to token metadata keys
+	ClaimMappings map[string]string `json:"claim_mappings,omitempty"`
+	//UserClaim is the claim used as the entity alias name
+	UserClaim string `json:"user_claim"`
+	//GroupsClaim is the claim used as the group alias names
+	GroupsClaim string `json:"groups_claim,omitempty"`
+	//OIDCScopes is the list of scopes requested by the oidc login flow
+	OIDCScopes []string `json:"oidc_scopes,omitempty"`
+	//AllowedRedirectURIs is the list of redirect URIs allowed by the oidc login flow
+	AllowedRedirectURIs []string `json:"allowed_redirect_uris,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+}
+
+// Validate returns an error if the spec cannot be written to vault
+func (s *JWTAuthRoleSpec) Validate() error {
+	if s.SysAuthRef == "" || s.Name == "" {
+		return fmt.Errorf("sysauth_ref and name are required")
+	}
+	if s.UserClaim == "" {
+		return fmt.Errorf("user_claim is required")
+	}
+	switch s.RoleType {
+	case "oidc":
+		if len(s.AllowedRedirectURIs) == 0 {
+			return fmt.Errorf("allowed_redirect_uris is required for oidc roles")
+		}
+		for _, uri := range s.AllowedRedirectURIs {
+			if err := validateURL(uri); err != nil {
+				return fmt.Errorf("invalid redirect uri %s: %v", uri, err)
+			}
+		}
+	case "", "jwt":
+		if len(s.BoundAudiences) == 0 && s.BoundSubject == "" && len(s.BoundClaims) == 0 {
+			return fmt.Errorf("jwt roles require one of bound_audiences, bound_subject or bound_claims")
+		}
+	default:
+		return fmt.Errorf("invalid role_type %s", s.RoleType)
+	}
+	return nil
+}
+
+// JWTAuthRoleStatus defines the observed state of JWTAuthRole
+type JWTAuthRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// JWTAuthRole is the Schema for the jwtauthroles API
+type JWTAuthRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *JWTAuthRoleSpec   `json:"spec,omitempty"`
+	Status *JWTAuthRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (j *JWTAuthRole) IsBeingDeleted() bool {
+	return !j.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a jwt auth role has been created
+func (j *JWTAuthRole) IsCreated() bool {
+	if j.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (j *JWTAuthRole) HasFinalizer(name string) bool {
+	return containsString(j.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (j *JWTAuthRole) AddFinalizer(name string) {
+	j.ObjectMeta.Finalizers = append(j.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (j *JWTAuthRole) RemoveFinalizer(name string) {
+	j.ObjectMeta.Finalizers = removeString(j.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (j *JWTAuthRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(j.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// JWTAuthRoleList contains a list of JWTAuthRole
+type JWTAuthRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JWTAuthRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JWTAuthRole{}, &JWTAuthRoleList{})
+}
@@ -0,0 +1,102 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//KubernetesAuthConfigFailedState state when failed
+	KubernetesAuthConfigFailedState = "failed"
+	//KubernetesAuthConfigCreatedState state when created
+	KubernetesAuthConfigCreatedState = "created"
+	//KubernetesAuthConfigUpdatedState state when updated
+	KubernetesAuthConfigUpdatedState = "updated"
+)
+
+// KubernetesAuthConfigSpec defines the desired state of KubernetesAuthConfig
+type KubernetesAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type kubernetes to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//KubernetesHost is the kubernetes API URL, defaults to the in-cluster host
+	KubernetesHost string `json:"kubernetes_host,omitempty"`
+	//KubernetesCACert is the PEM encoded kubernetes CA, defaults to the in-cluster CA
+	KubernetesCACert string `json:"kubernetes_ca_cert,omitempty"`
+	//TokenReviewerJWT references the secret key holding the token reviewer JWT
+	TokenReviewerJWT *SecretKeyReference `json:"token_reviewer_jwt,omitempty"`
+	//Issuer is the expected issuer of service account tokens
+	Issuer string `json:"issuer,omitempty"`
+	//DisableISSValidation disables the validation of the token issuer
+	DisableISSValidation bool `json:"disable_iss_validation,omitempty"`
+}
+
+// KubernetesAuthConfigStatus defines the observed state of KubernetesAuthConfig
+type KubernetesAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//KubernetesHost is the kubernetes API URL written to vault
+	KubernetesHost string `json:"kubernetes_host,omitempty"`
+	//TokenReviewerJWTVersion is the resource version of the token reviewer JWT secret
+	TokenReviewerJWTVersion string `json:"token_reviewer_jwt_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KubernetesAuthConfig is the Schema for the kubernetesauthconfigs API
+type KubernetesAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *KubernetesAuthConfigSpec   `json:"spec,omitempty"`
+	Status *KubernetesAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (k *KubernetesAuthConfig) IsBeingDeleted() bool {
+	return !k.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a kubernetes auth config has been written
+func (k *KubernetesAuthConfig) IsCreated() bool {
+	if k.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (k *KubernetesAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(k.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// KubernetesAuthConfigList contains a list of KubernetesAuthConfig
+type KubernetesAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubernetesAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubernetesAuthConfig{}, &KubernetesAuthConfigList{})
+}
@@ -0,0 +1,128 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//KubernetesAuthRoleFinalizer name of the kubernetesauthrole finalizer
+	KubernetesAuthRoleFinalizer = "kubernetesauthrole.finalizers.vault.gobins.github.io"
+	//KubernetesAuthRoleFailedState state when failed
+	KubernetesAuthRoleFailedState = "failed"
+	//KubernetesAuthRoleCreatedState state when created
+	KubernetesAuthRoleCreatedState = "created"
+	//KubernetesAuthRoleUpdatedState state when updated
+	KubernetesAuthRoleUpdatedState = "updated"
+)
+
+// KubernetesAuthRoleSpec defines the desired state of KubernetesAuthRole
+type KubernetesAuthRoleSpec struct {
+	//SysAuthRef is the name of the SysAuth of type kubernetes holding the role
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the role name
+	Name string `json:"name"`
+	//BoundServiceAccountNames is the list of service account names able to login
+	BoundServiceAccountNames []string `json:"bound_service_account_names,omitempty"`
+	//BoundServiceAccountNamespaces is the list of namespaces allowed to login
+	BoundServiceAccountNamespaces []string `json:"bound_service_account_namespaces,omitempty"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	//PolicyRefs is the list of Policy objects whose policies are attached to issued tokens
+	PolicyRefs  []string `json:"policy_refs,omitempty"`
+	TokenTTL    string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL string   `json:"token_max_ttl,omitempty"`
+	//Audience is the optional audience claim to verify in the JWT
+	Audience string `json:"audience,omitempty"`
+	//AliasNameSource is either serviceaccount_uid or serviceaccount_name
+	// +kubebuilder:validation:Enum=serviceaccount_uid;serviceaccount_name
+	AliasNameSource string `json:"alias_name_source,omitempty"`
+}
+
+// KubernetesAuthRoleStatus defines the observed state of KubernetesAuthRole
+type KubernetesAuthRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the role was written to
+	Path string `json:"path,omitempty"`
+	//Policies is the resolved list of policies written to the role
+	Policies []string `json:"policies,omitempty"`
+	//MissingPolicyRefs lists the policy refs which do not exist or are not created yet
+	MissingPolicyRefs []string `json:"missing_policy_refs,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KubernetesAuthRole is the Schema for the kubernetesauthroles API
+type KubernetesAuthRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *KubernetesAuthRoleSpec   `json:"spec,omitempty"`
+	Status *KubernetesAuthRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (k *KubernetesAuthRole) IsBeingDeleted() bool {
+	return !k.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a kubernetes auth role has been created
+func (k *KubernetesAuthRole) IsCreated() bool {
+	if k.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (k *KubernetesAuthRole) HasFinalizer(name string) bool {
+	return containsString(k.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (k *KubernetesAuthRole) AddFinalizer(name string) {
+	k.ObjectMeta.Finalizers = append(k.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (k *KubernetesAuthRole) RemoveFinalizer(name string) {
+	k.ObjectMeta.Finalizers = removeString(k.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (k *KubernetesAuthRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(k.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// KubernetesAuthRoleList contains a list of KubernetesAuthRole
+type KubernetesAuthRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubernetesAuthRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubernetesAuthRole{}, &KubernetesAuthRoleList{})
+}
@@ -0,0 +1,99 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//KVConfigFailedState state when failed
+	KVConfigFailedState = "failed"
+	//KVConfigCreatedState state when created
+	KVConfigCreatedState = "created"
+	//KVConfigUpdatedState state when updated
+	KVConfigUpdatedState = "updated"
+)
+
+// KVConfigSpec defines the desired state of KVConfig
+type KVConfigSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type kv version 2 to configure
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the kv mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//MaxVersions is the number of versions kept, vault defaults to 10 when 0
+	// +kubebuilder:validation:Minimum=0
+	MaxVersions int `json:"max_versions,omitempty"`
+	//CASRequired requires writes to use check-and-set
+	CASRequired bool `json:"cas_required,omitempty"`
+	//DeleteVersionAfter is the duration after which versions are deleted, versions are kept when unset
+	DeleteVersionAfter string `json:"delete_version_after,omitempty"`
+}
+
+// KVConfigStatus defines the observed state of KVConfig
+type KVConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the kv mount path the settings were written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KVConfig is the Schema for the kvconfigs API
+type KVConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *KVConfigSpec   `json:"spec,omitempty"`
+	Status *KVConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (k *KVConfig) IsBeingDeleted() bool {
+	return !k.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a kv config has been written
+func (k *KVConfig) IsCreated() bool {
+	if k.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (k *KVConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(k.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// KVConfigList contains a list of KVConfig
+type KVConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KVConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KVConfig{}, &KVConfigList{})
+}
@@ -0,0 +1,122 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//KVMetadataFinalizer name of the kvmetadata finalizer
+	KVMetadataFinalizer = "kvmetadata.finalizers.vault.gobins.github.io"
+	//KVMetadataFailedState state when failed
+	KVMetadataFailedState = "failed"
+	//KVMetadataCreatedState state when created
+	KVMetadataCreatedState = "created"
+	//KVMetadataUpdatedState state when updated
+	KVMetadataUpdatedState = "updated"
+)
+
+// KVMetadataSpec defines the desired state of KVMetadata
+type KVMetadataSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type kv version 2 holding the path
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the kv mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Path is the secret path within the mount, the metadata of nested paths is not affected
+	Path string `json:"path"`
+	//MaxVersions is the number of versions kept, the mount setting applies when 0
+	// +kubebuilder:validation:Minimum=0
+	MaxVersions int `json:"max_versions,omitempty"`
+	//CASRequired requires writes to use check-and-set
+	CASRequired bool `json:"cas_required,omitempty"`
+	//DeleteVersionAfter is the duration after which versions are deleted, the mount setting applies when unset
+	DeleteVersionAfter string `json:"delete_version_after,omitempty"`
+	//CustomMetadata are user provided key value pairs describing the secret
+	CustomMetadata map[string]string `json:"custom_metadata,omitempty"`
+}
+
+// KVMetadataStatus defines the observed state of KVMetadata
+type KVMetadataStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the kv mount path the metadata was written to
+	Path string `json:"path,omitempty"`
+	//SecretPath is the secret path within the mount the metadata was written to
+	SecretPath string `json:"secret_path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KVMetadata is the Schema for the kvmetadata API
+type KVMetadata struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *KVMetadataSpec   `json:"spec,omitempty"`
+	Status *KVMetadataStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (k *KVMetadata) IsBeingDeleted() bool {
+	return !k.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if the metadata has been written
+func (k *KVMetadata) IsCreated() bool {
+	if k.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (k *KVMetadata) HasFinalizer(name string) bool {
+	return containsString(k.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (k *KVMetadata) AddFinalizer(name string) {
+	k.ObjectMeta.Finalizers = append(k.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (k *KVMetadata) RemoveFinalizer(name string) {
+	k.ObjectMeta.Finalizers = removeString(k.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (k *KVMetadata) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(k.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// KVMetadataList contains a list of KVMetadata
+type KVMetadataList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KVMetadata `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KVMetadata{}, &KVMetadataList{})
+}
@@ -0,0 +1,129 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//KVPushFinalizer name of the kvpush finalizer
+	KVPushFinalizer = "kvpush.finalizers.vault.gobins.github.io"
+	//KVPushFailedState state when failed
+	KVPushFailedState = "failed"
+	//KVPushCreatedState state when created
+	KVPushCreatedState = "created"
+	//KVPushUpdatedState state when updated
+	KVPushUpdatedState = "updated"
+	//KVPushConflictState state when a write was rejected by check-and-set
+	KVPushConflictState = "conflict"
+)
+
+// KVPushSpec defines the desired state of KVPush
+type KVPushSpec struct {
+	//SecretName is the name of the source Secret
+	SecretName string `json:"secret_name"`
+	//Keys is the list of Secret keys to write, all keys are written when empty
+	Keys []string `json:"keys,omitempty"`
+	//Mount is the path of the KV v2 secrets engine
+	Mount string `json:"mount"`
+	//Path is the secret path within the mount
+	Path string `json:"path"`
+	//Overwrite writes over versions created by other writers instead of reporting a check-and-set conflict
+	Overwrite bool `json:"overwrite,omitempty"`
+	//DestroyMetadataOnDelete removes every version and the metadata of the KV secret when the object is deleted
+	DestroyMetadataOnDelete bool `json:"destroy_metadata_on_delete,omitempty"`
+}
+
+// KVPushStatus defines the observed state of KVPush
+type KVPushStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Version is the KV version last written by the controller
+	Version int64 `json:"version,omitempty"`
+	//Mount is the mount last written by the controller
+	Mount string `json:"mount,omitempty"`
+	//Path is the secret path last written by the controller
+	Path string `json:"path,omitempty"`
+	//DataHash is a hash of the data last written
+	DataHash string `json:"data_hash,omitempty"`
+	//Conflict describes the last check-and-set conflict, empty once a write succeeds
+	Conflict string `json:"conflict,omitempty"`
+	//ConflictVersion is the KV version found when the last conflict happened
+	ConflictVersion int64 `json:"conflict_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KVPush is the Schema for the kvpushes API
+type KVPush struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *KVPushSpec   `json:"spec,omitempty"`
+	Status *KVPushStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (k *KVPush) IsBeingDeleted() bool {
+	return !k.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if the data has been written to vault
+func (k *KVPush) IsCreated() bool {
+	if k.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (k *KVPush) HasFinalizer(name string) bool {
+	return containsString(k.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (k *KVPush) AddFinalizer(name string) {
+	k.ObjectMeta.Finalizers = append(k.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (k *KVPush) RemoveFinalizer(name string) {
+	k.ObjectMeta.Finalizers = removeString(k.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (k *KVPush) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(k.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// KVPushList contains a list of KVPush
+type KVPushList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KVPush `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KVPush{}, &KVPushList{})
+}
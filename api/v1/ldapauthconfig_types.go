@@ -0,0 +1,114 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//LDAPAuthConfigFailedState state when failed
+	LDAPAuthConfigFailedState = "failed"
+	//LDAPAuthConfigCreatedState state when created
+	LDAPAuthConfigCreatedState = "created"
+	//LDAPAuthConfigUpdatedState state when updated
+	LDAPAuthConfigUpdatedState = "updated"
+)
+
+// LDAPAuthConfigSpec defines the desired state of LDAPAuthConfig
+type LDAPAuthConfigSpec struct {
+	//SysAuthRef is the name of the SysAuth of type ldap to configure
+	SysAuthRef string `json:"sysauth_ref"`
+	//URL is the LDAP server URL, multiple URLs can be comma separated
+	URL string `json:"url"`
+	//BindDN is the distinguished name used to search users and groups
+	BindDN string `json:"binddn,omitempty"`
+	//BindPass references the secret key holding the password of the bind DN
+	BindPass *SecretKeyReference `json:"bindpass,omitempty"`
+	//UserDN is the base DN under which to search users
+	UserDN string `json:"userdn,omitempty"`
+	//UserAttr is the attribute matched against the login username
+	UserAttr string `json:"userattr,omitempty"`
+	//UserFilter is the go template filter used to search users
+	UserFilter string `json:"userfilter,omitempty"`
+	//UPNDomain is the userPrincipalDomain used to build the bind DN of users
+	UPNDomain string `json:"upndomain,omitempty"`
+	//GroupDN is the base DN under which to search groups
+	GroupDN string `json:"groupdn,omitempty"`
+	//GroupFilter is the go template filter used to search groups
+	GroupFilter string `json:"groupfilter,omitempty"`
+	//GroupAttr is the attribute holding the group names
+	GroupAttr string `json:"groupattr,omitempty"`
+	//Certificate is the PEM encoded CA used to verify the LDAP server
+	Certificate string `json:"certificate,omitempty"`
+	InsecureTLS bool   `json:"insecure_tls,omitempty"`
+	StartTLS    bool   `json:"starttls,omitempty"`
+}
+
+// LDAPAuthConfigStatus defines the observed state of LDAPAuthConfig
+type LDAPAuthConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//BindPassVersion is the resource version of the bind password secret
+	BindPassVersion string `json:"bindpass_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// LDAPAuthConfig is the Schema for the ldapauthconfigs API
+type LDAPAuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *LDAPAuthConfigSpec   `json:"spec,omitempty"`
+	Status *LDAPAuthConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (l *LDAPAuthConfig) IsBeingDeleted() bool {
+	return !l.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a ldap auth config has been written
+func (l *LDAPAuthConfig) IsCreated() bool {
+	if l.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetHash returns a hash of the struct
+func (l *LDAPAuthConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(l.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// LDAPAuthConfigList contains a list of LDAPAuthConfig
+type LDAPAuthConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LDAPAuthConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LDAPAuthConfig{}, &LDAPAuthConfigList{})
+}
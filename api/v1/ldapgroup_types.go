@@ -0,0 +1,111 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//LDAPGroupFinalizer name of the ldapgroup finalizer
+	LDAPGroupFinalizer = "ldapgroup.finalizers.vault.gobins.github.io"
+	//LDAPGroupFailedState state when failed
+	LDAPGroupFailedState = "failed"
+	//LDAPGroupCreatedState state when created
+	LDAPGroupCreatedState = "created"
+	//LDAPGroupUpdatedState state when updated
+	LDAPGroupUpdatedState = "updated"
+)
+
+// LDAPGroupSpec defines the desired state of LDAPGroup
+type LDAPGroupSpec struct {
+	//SysAuthRef is the name of the SysAuth of type ldap holding the group
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the LDAP group name
+	Name string `json:"name"`
+	//Policies is the list of vault policies attached to the group
+	Policies []string `json:"policies,omitempty"`
+}
+
+// LDAPGroupStatus defines the observed state of LDAPGroup
+type LDAPGroupStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the group was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// LDAPGroup is the Schema for the ldapgroups API
+type LDAPGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *LDAPGroupSpec   `json:"spec,omitempty"`
+	Status *LDAPGroupStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (l *LDAPGroup) IsBeingDeleted() bool {
+	return !l.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a ldap group has been created
+func (l *LDAPGroup) IsCreated() bool {
+	if l.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (l *LDAPGroup) HasFinalizer(name string) bool {
+	return containsString(l.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (l *LDAPGroup) AddFinalizer(name string) {
+	l.ObjectMeta.Finalizers = append(l.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (l *LDAPGroup) RemoveFinalizer(name string) {
+	l.ObjectMeta.Finalizers = removeString(l.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (l *LDAPGroup) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(l.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// LDAPGroupList contains a list of LDAPGroup
+type LDAPGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LDAPGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LDAPGroup{}, &LDAPGroupList{})
+}
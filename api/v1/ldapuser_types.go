@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//LDAPUserFinalizer name of the ldapuser finalizer
+	LDAPUserFinalizer = "ldapuser.finalizers.vault.gobins.github.io"
+	//LDAPUserFailedState state when failed
+	LDAPUserFailedState = "failed"
+	//LDAPUserCreatedState state when created
+	LDAPUserCreatedState = "created"
+	//LDAPUserUpdatedState state when updated
+	LDAPUserUpdatedState = "updated"
+)
+
+// LDAPUserSpec defines the desired state of LDAPUser
+type LDAPUserSpec struct {
+	//SysAuthRef is the name of the SysAuth of type ldap holding the user
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the LDAP user name
+	Name string `json:"name"`
+	//Policies is the list of vault policies attached to the user
+	Policies []string `json:"policies,omitempty"`
+	//Groups is the list of LDAP groups the user is added to
+	Groups []string `json:"groups,omitempty"`
+}
+
+// LDAPUserStatus defines the observed state of LDAPUser
+type LDAPUserStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the user was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// LDAPUser is the Schema for the ldapusers API
+type LDAPUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *LDAPUserSpec   `json:"spec,omitempty"`
+	Status *LDAPUserStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (l *LDAPUser) IsBeingDeleted() bool {
+	return !l.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a ldap user has been created
+func (l *LDAPUser) IsCreated() bool {
+	if l.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (l *LDAPUser) HasFinalizer(name string) bool {
+	return containsString(l.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (l *LDAPUser) AddFinalizer(name string) {
+	l.ObjectMeta.Finalizers = append(l.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (l *LDAPUser) RemoveFinalizer(name string) {
+	l.ObjectMeta.Finalizers = removeString(l.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (l *LDAPUser) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(l.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// LDAPUserList contains a list of LDAPUser
+type LDAPUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LDAPUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LDAPUser{}, &LDAPUserList{})
+}
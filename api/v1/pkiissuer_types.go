@@ -0,0 +1,183 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//PKIIssuerFailedState state when failed
+	PKIIssuerFailedState = "failed"
+	//PKIIssuerCreatedState state when created
+	PKIIssuerCreatedState = "created"
+	//PKIIssuerUpdatedState state when updated
+	PKIIssuerUpdatedState = "updated"
+	//PKIIssuerPendingState state when waiting for the parent issuer
+	PKIIssuerPendingState = "pending"
+	//PKIIssuerOutdatedState state when the CA no longer matches the spec or its parent and has to be re-issued
+	PKIIssuerOutdatedState = "outdated"
+	//PKIIssuerRootType type of a self signed issuer
+	PKIIssuerRootType = "root"
+	//PKIIssuerIntermediateType type of an issuer signed by its parent
+	PKIIssuerIntermediateType = "intermediate"
+	//PKIIssuerChainKey is the ConfigMap key holding the CA chain
+	PKIIssuerChainKey = "ca.crt"
+)
+
+// PKIIssuerSpec defines the desired state of PKIIssuer
+type PKIIssuerSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type pki holding the issuer
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the pki mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Type is either root or intermediate
+	// +kubebuilder:validation:Enum=root;intermediate
+	Type string `json:"type"`
+	//ParentRef is the name of the PKIIssuer signing an intermediate
+	ParentRef  string   `json:"parent_ref,omitempty"`
+	CommonName string   `json:"common_name"`
+	AltNames   []string `json:"alt_names,omitempty"`
+	TTL        string   `json:"ttl,omitempty"`
+	//KeyType is the type of the generated key, vault defaults to rsa
+	// +kubebuilder:validation:Enum=rsa;ec;ed25519
+	KeyType string `json:"key_type,omitempty"`
+	KeyBits int    `json:"key_bits,omitempty"`
+	//IssuingCertificates is the list of URLs of the issuing certificate
+	IssuingCertificates []string `json:"issuing_certificates,omitempty"`
+	//CRLDistributionPoints is the list of URLs of the CRL
+	CRLDistributionPoints []string `json:"crl_distribution_points,omitempty"`
+	//OCSPServers is the list of URLs of the OCSP responders
+	OCSPServers []string `json:"ocsp_servers,omitempty"`
+	//ConfigMapName is the name of the ConfigMap the CA chain is published to, defaults to the object name
+	ConfigMapName string `json:"configmap_name,omitempty"`
+}
+
+// Validate returns an error if the issuer cannot be bootstrapped
+func (s *PKIIssuerSpec) Validate() error {
+	if s.CommonName == "" {
+		return fmt.Errorf("common_name is required")
+	}
+	switch s.Type {
+	case PKIIssuerRootType:
+		if s.ParentRef != "" {
+			return fmt.Errorf("parent_ref is only allowed for intermediate issuers")
+		}
+	case PKIIssuerIntermediateType:
+		if s.ParentRef == "" {
+			return fmt.Errorf("parent_ref is required for intermediate issuers")
+		}
+	default:
+		return fmt.Errorf("type must be root or intermediate")
+	}
+	return nil
+}
+
+// PKIIssuerStatus defines the observed state of PKIIssuer
+type PKIIssuerStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the pki mount path of the issuer
+	Path         string `json:"path,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	//NotAfter is the expiry of the CA certificate
+	NotAfter *metav1.Time `json:"not_after,omitempty"`
+	//ConfigMapName is the name of the ConfigMap holding the CA chain
+	ConfigMapName string `json:"configmap_name,omitempty"`
+	//ParentChainVersion is the resource version of the parent chain ConfigMap the chain was built from
+	ParentChainVersion string `json:"parent_chain_version,omitempty"`
+	//IssueHash is the hash of the spec fields the CA certificate was generated from
+	IssueHash string `json:"issue_hash,omitempty"`
+	//ParentSerialNumber is the serial number of the parent CA which signed the intermediate
+	ParentSerialNumber string `json:"parent_serial_number,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PKIIssuer is the Schema for the pkiissuers API
+type PKIIssuer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *PKIIssuerSpec   `json:"spec,omitempty"`
+	Status *PKIIssuerStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (p *PKIIssuer) IsBeingDeleted() bool {
+	return !p.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a pki issuer has been created
+func (p *PKIIssuer) IsCreated() bool {
+	if p.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetConfigMapName returns the name of the ConfigMap to publish the CA chain to
+func (p *PKIIssuer) GetConfigMapName() string {
+	if p.Spec.ConfigMapName != "" {
+		return p.Spec.ConfigMapName
+	}
+	return p.GetName()
+}
+
+// GetIssueHash returns a hash of the spec fields the CA certificate is generated from
+func (p *PKIIssuer) GetIssueHash() (string, error) {
+	hash, err := hashstructure.Hash(struct {
+		Type       string
+		ParentRef  string
+		CommonName string
+		AltNames   []string
+		TTL        string
+		KeyType    string
+		KeyBits    int
+	}{
+		Type:       p.Spec.Type,
+		ParentRef:  p.Spec.ParentRef,
+		CommonName: p.Spec.CommonName,
+		AltNames:   p.Spec.AltNames,
+		TTL:        p.Spec.TTL,
+		KeyType:    p.Spec.KeyType,
+		KeyBits:    p.Spec.KeyBits,
+	}, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// GetHash returns a hash of the struct
+func (p *PKIIssuer) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(p.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// PKIIssuerList contains a list of PKIIssuer
+type PKIIssuerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PKIIssuer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PKIIssuer{}, &PKIIssuerList{})
+}
@@ -0,0 +1,154 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//PKIRoleFinalizer name of the pkirole finalizer
+	PKIRoleFinalizer = "pkirole.finalizers.vault.gobins.github.io"
+	//PKIRoleFailedState state when failed
+	PKIRoleFailedState = "failed"
+	//PKIRoleCreatedState state when created
+	PKIRoleCreatedState = "created"
+	//PKIRoleUpdatedState state when updated
+	PKIRoleUpdatedState = "updated"
+)
+
+// PKIRoleSpec defines the desired state of PKIRole
+type PKIRoleSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type pki holding the role
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the pki mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Name is the role name
+	Name string `json:"name"`
+	//AllowedDomains is the list of domains certificates can be issued for
+	AllowedDomains   []string `json:"allowed_domains,omitempty"`
+	AllowSubdomains  bool     `json:"allow_subdomains,omitempty"`
+	AllowBareDomains bool     `json:"allow_bare_domains,omitempty"`
+	AllowGlobDomains bool     `json:"allow_glob_domains,omitempty"`
+	AllowAnyName     bool     `json:"allow_any_name,omitempty"`
+	//EnforceHostnames only allows valid host names, vault defaults to true
+	EnforceHostnames *bool `json:"enforce_hostnames,omitempty"`
+	//KeyType is the type of the generated keys, vault defaults to rsa
+	// +kubebuilder:validation:Enum=rsa;ec;ed25519;any
+	KeyType string `json:"key_type,omitempty"`
+	//KeyBits is the size of the generated keys, vault picks a default for the key type
+	KeyBits int    `json:"key_bits,omitempty"`
+	TTL     string `json:"ttl,omitempty"`
+	MaxTTL  string `json:"max_ttl,omitempty"`
+	//ExtKeyUsage is the list of extended key usages, such as ServerAuth or ClientAuth
+	ExtKeyUsage []string `json:"ext_key_usage,omitempty"`
+	ServerFlag  *bool    `json:"server_flag,omitempty"`
+	ClientFlag  *bool    `json:"client_flag,omitempty"`
+	//IssuerRef is the issuer used to sign certificates, vault uses the default issuer when unset
+	IssuerRef string `json:"issuer_ref,omitempty"`
+}
+
+// PKIRoleEffective is the role as applied by vault
+type PKIRoleEffective struct {
+	AllowedDomains   []string `json:"allowed_domains,omitempty"`
+	AllowSubdomains  bool     `json:"allow_subdomains"`
+	AllowBareDomains bool     `json:"allow_bare_domains"`
+	AllowGlobDomains bool     `json:"allow_glob_domains"`
+	AllowAnyName     bool     `json:"allow_any_name"`
+	EnforceHostnames bool     `json:"enforce_hostnames"`
+	KeyType          string   `json:"key_type,omitempty"`
+	KeyBits          int64    `json:"key_bits,omitempty"`
+	//TTL is the effective ttl in seconds
+	TTL int64 `json:"ttl,omitempty"`
+	//MaxTTL is the effective max_ttl in seconds
+	MaxTTL      int64    `json:"max_ttl,omitempty"`
+	ExtKeyUsage []string `json:"ext_key_usage,omitempty"`
+	ServerFlag  bool     `json:"server_flag"`
+	ClientFlag  bool     `json:"client_flag"`
+	IssuerRef   string   `json:"issuer_ref,omitempty"`
+}
+
+// PKIRoleStatus defines the observed state of PKIRole
+type PKIRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the pki mount path the role was written to
+	Path string `json:"path,omitempty"`
+	//Effective echoes the role as read back from vault, including the defaults it applied
+	Effective *PKIRoleEffective `json:"effective,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PKIRole is the Schema for the pkiroles API
+type PKIRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *PKIRoleSpec   `json:"spec,omitempty"`
+	Status *PKIRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (p *PKIRole) IsBeingDeleted() bool {
+	return !p.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a pki role has been created
+func (p *PKIRole) IsCreated() bool {
+	if p.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (p *PKIRole) HasFinalizer(name string) bool {
+	return containsString(p.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (p *PKIRole) AddFinalizer(name string) {
+	p.ObjectMeta.Finalizers = append(p.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (p *PKIRole) RemoveFinalizer(name string) {
+	p.ObjectMeta.Finalizers = removeString(p.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (p *PKIRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(p.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// PKIRoleList contains a list of PKIRole
+type PKIRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PKIRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PKIRole{}, &PKIRoleList{})
+}
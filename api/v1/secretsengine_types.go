@@ -0,0 +1,145 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//SecretsEngineFinalizer name of the secretsengine finalizer
+	SecretsEngineFinalizer = "secretsengine.finalizers.vault.gobins.github.io"
+	//SecretsEngineFailedState state when failed
+	SecretsEngineFailedState = "failed"
+	//SecretsEngineCreatedState state when created
+	SecretsEngineCreatedState = "created"
+	//SecretsEngineUpdatedState state when updated
+	SecretsEngineUpdatedState = "updated"
+	//SecretsEngineDeletionPolicyDelete unmounts the engine when the object is deleted
+	SecretsEngineDeletionPolicyDelete = "Delete"
+	//SecretsEngineDeletionPolicyRetain keeps the engine and its data when the object is deleted
+	SecretsEngineDeletionPolicyRetain = "Retain"
+)
+
+// SecretsEngineSpec defines the desired state of SecretsEngine
+type SecretsEngineSpec struct {
+	//Path is the mount path, changing it remounts the engine
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+	//Type is the secrets engine type, such as kv, pki, transit or database
+	Type string `json:"type"`
+	//Options are the engine specific mount options, such as version for kv
+	Options map[string]string `json:"options,omitempty"`
+	//Local marks the mount as local to the cluster, it can't be changed once mounted
+	Local bool `json:"local,omitempty"`
+	//SealWrap enables seal wrapping for the mount, it can't be changed once mounted
+	SealWrap bool `json:"seal_wrap,omitempty"`
+	//ExternalEntropyAccess gives the mount access to the external entropy source, it can't be changed once mounted
+	ExternalEntropyAccess bool                `json:"external_entropy_access,omitempty"`
+	Config                SecretsEngineConfig `json:"config,omitempty"`
+	//DeletionPolicy is either Delete to unmount the engine along with the object or Retain to keep it
+	// +kubebuilder:validation:Enum=Delete;Retain
+	DeletionPolicy string `json:"deletion_policy,omitempty"`
+	//DriftCheckInterval is how often the mount is compared to the spec, defaults to 5m
+	DriftCheckInterval string `json:"drift_check_interval,omitempty"`
+}
+
+// SecretsEngineConfig define the tunable config of a SecretsEngine
+type SecretsEngineConfig struct {
+	DefaultLeaseTTL string `json:"default_lease_ttl,omitempty"`
+	MaxLeaseTTL     string `json:"max_lease_ttl,omitempty"`
+}
+
+// SecretsEngineStatus defines the observed state of SecretsEngine
+type SecretsEngineStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the path the engine is mounted at
+	Path string `json:"path,omitempty"`
+	//Accessor is the mount accessor
+	Accessor string `json:"accessor,omitempty"`
+	//Drift lists the settings found changed outside of the controller at the last check
+	Drift []string `json:"drift,omitempty"`
+	//LastDriftCheck is the time the mount was last compared to the spec
+	LastDriftCheck *metav1.Time `json:"last_drift_check,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SecretsEngine is the Schema for the secretsengines API
+type SecretsEngine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *SecretsEngineSpec   `json:"spec,omitempty"`
+	Status *SecretsEngineStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (s *SecretsEngine) IsBeingDeleted() bool {
+	return !s.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a secrets engine has been mounted
+func (s *SecretsEngine) IsCreated() bool {
+	if s.Status == nil {
+		return false
+	}
+	return true
+}
+
+// IsRetained returns true if the engine must be kept when the object is deleted
+func (s *SecretsEngine) IsRetained() bool {
+	return s.Spec != nil && s.Spec.DeletionPolicy == SecretsEngineDeletionPolicyRetain
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (s *SecretsEngine) HasFinalizer(name string) bool {
+	return containsString(s.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (s *SecretsEngine) AddFinalizer(name string) {
+	s.ObjectMeta.Finalizers = append(s.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (s *SecretsEngine) RemoveFinalizer(name string) {
+	s.ObjectMeta.Finalizers = removeString(s.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (s *SecretsEngine) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(s.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// SecretsEngineList contains a list of SecretsEngine
+type SecretsEngineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SecretsEngine `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SecretsEngine{}, &SecretsEngineList{})
+}
@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//SSHCAConfigFailedState state when failed
+	SSHCAConfigFailedState = "failed"
+	//SSHCAConfigCreatedState state when created
+	SSHCAConfigCreatedState = "created"
+	//SSHCAConfigUpdatedState state when updated
+	SSHCAConfigUpdatedState = "updated"
+	//SSHCAConfigPublicKeyKey is the ConfigMap key holding the CA public key
+	SSHCAConfigPublicKeyKey = "trusted-user-ca-keys.pem"
+)
+
+// SSHCAConfigSpec defines the desired state of SSHCAConfig
+type SSHCAConfigSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type ssh to configure
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the ssh mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//PrivateKey references the secret key holding the CA private key to import, a key is generated when unset
+	PrivateKey *SecretKeyReference `json:"private_key,omitempty"`
+	//PublicKey references the secret key holding the CA public key to import
+	PublicKey *SecretKeyReference `json:"public_key,omitempty"`
+	//KeyType is the type of the generated key
+	// +kubebuilder:validation:Enum=ssh-rsa;ecdsa-sha2-nistp256;ecdsa-sha2-nistp384;ecdsa-sha2-nistp521;ssh-ed25519
+	KeyType string `json:"key_type,omitempty"`
+	//KeyBits is the size of the generated key
+	KeyBits int `json:"key_bits,omitempty"`
+	//ConfigMapName is the name of the ConfigMap the CA public key is published to, defaults to the object name
+	ConfigMapName string `json:"configmap_name,omitempty"`
+	//Force replaces a different CA already configured in the mount by the imported one
+	Force bool `json:"force,omitempty"`
+}
+
+// Validate returns an error if the CA cannot be configured
+func (s *SSHCAConfigSpec) Validate() error {
+	if (s.PrivateKey == nil) != (s.PublicKey == nil) {
+		return fmt.Errorf("private_key and public_key must be set together")
+	}
+	if s.PrivateKey != nil && (s.KeyType != "" || s.KeyBits != 0) {
+		return fmt.Errorf("key_type and key_bits are only allowed for a generated key")
+	}
+	return nil
+}
+
+// SSHCAConfigStatus defines the observed state of SSHCAConfig
+type SSHCAConfigStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the ssh mount path of the CA
+	Path string `json:"path,omitempty"`
+	//SecretsVersion is the resource version of the imported key secrets
+	SecretsVersion string `json:"secrets_version,omitempty"`
+	//ConfigMapName is the name of the ConfigMap holding the CA public key
+	ConfigMapName string `json:"configmap_name,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SSHCAConfig is the Schema for the sshcaconfigs API
+type SSHCAConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *SSHCAConfigSpec   `json:"spec,omitempty"`
+	Status *SSHCAConfigStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (s *SSHCAConfig) IsBeingDeleted() bool {
+	return !s.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an ssh CA has been configured
+func (s *SSHCAConfig) IsCreated() bool {
+	if s.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetConfigMapName returns the name of the ConfigMap to publish the CA public key to
+func (s *SSHCAConfig) GetConfigMapName() string {
+	if s.Spec.ConfigMapName != "" {
+		return s.Spec.ConfigMapName
+	}
+	return s.GetName()
+}
+
+// GetHash returns a hash of the struct
+func (s *SSHCAConfig) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(s.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// SSHCAConfigList contains a list of SSHCAConfig
+type SSHCAConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SSHCAConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SSHCAConfig{}, &SSHCAConfigList{})
+}
@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//SSHRoleFinalizer name of the sshrole finalizer
+	SSHRoleFinalizer = "sshrole.finalizers.vault.gobins.github.io"
+	//SSHRoleFailedState state when failed
+	SSHRoleFailedState = "failed"
+	//SSHRoleCreatedState state when created
+	SSHRoleCreatedState = "created"
+	//SSHRoleUpdatedState state when updated
+	SSHRoleUpdatedState = "updated"
+)
+
+// SSHRoleSpec defines the desired state of SSHRole
+type SSHRoleSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type ssh holding the role
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the ssh mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Name is the role name
+	Name string `json:"name"`
+	//KeyType is the type of credentials of the role
+	// +kubebuilder:validation:Enum=ca;otp
+	KeyType string `json:"key_type"`
+	//AllowedUsers is the list of users certificates can be signed for, * allows any
+	AllowedUsers []string `json:"allowed_users,omitempty"`
+	//DefaultUser is the user used when none is requested
+	DefaultUser string `json:"default_user,omitempty"`
+	//AllowedExtensions is the list of extensions users can request, * allows any
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+	//DefaultExtensions are the extensions set on signed certificates, such as permit-pty
+	DefaultExtensions     map[string]string `json:"default_extensions,omitempty"`
+	AllowUserCertificates bool              `json:"allow_user_certificates,omitempty"`
+	AllowHostCertificates bool              `json:"allow_host_certificates,omitempty"`
+	//AllowedDomains is the list of domains host certificates can be signed for
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	TTL            string   `json:"ttl,omitempty"`
+	MaxTTL         string   `json:"max_ttl,omitempty"`
+	//AlgorithmSigner is the signing algorithm for rsa CA keys, such as rsa-sha2-256
+	AlgorithmSigner string `json:"algorithm_signer,omitempty"`
+}
+
+// SSHRoleStatus defines the observed state of SSHRole
+type SSHRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the ssh mount path the role was written to
+	Path string `json:"path,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SSHRole is the Schema for the sshroles API
+type SSHRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *SSHRoleSpec   `json:"spec,omitempty"`
+	Status *SSHRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (s *SSHRole) IsBeingDeleted() bool {
+	return !s.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if an ssh role has been created
+func (s *SSHRole) IsCreated() bool {
+	if s.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (s *SSHRole) HasFinalizer(name string) bool {
+	return containsString(s.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (s *SSHRole) AddFinalizer(name string) {
+	s.ObjectMeta.Finalizers = append(s.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (s *SSHRole) RemoveFinalizer(name string) {
+	s.ObjectMeta.Finalizers = removeString(s.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (s *SSHRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(s.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// SSHRoleList contains a list of SSHRole
+type SSHRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SSHRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SSHRole{}, &SSHRoleList{})
+}
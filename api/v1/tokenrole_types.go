@@ -0,0 +1,122 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//TokenRoleFinalizer name of the tokenrole finalizer
+	TokenRoleFinalizer = "tokenrole.finalizers.vault.gobins.github.io"
+	//TokenRoleFailedState state when failed
+	TokenRoleFailedState = "failed"
+	//TokenRoleCreatedState state when created
+	TokenRoleCreatedState = "created"
+	//TokenRoleUpdatedState state when updated
+	TokenRoleUpdatedState = "updated"
+)
+
+// TokenRoleSpec defines the desired state of TokenRole
+type TokenRoleSpec struct {
+	//Name is the token role name
+	Name string `json:"name"`
+	//AllowedPolicies is the list of policies tokens created against the role may have
+	AllowedPolicies []string `json:"allowed_policies,omitempty"`
+	//UnmanagedPolicies lists allowed_policies entries accepted without a Policy object, such as default
+	UnmanagedPolicies []string `json:"unmanaged_policies,omitempty"`
+	//DisallowedPolicies is the list of policies tokens created against the role must not have
+	DisallowedPolicies []string `json:"disallowed_policies,omitempty"`
+	//Orphan creates tokens without a parent
+	Orphan bool `json:"orphan,omitempty"`
+	//Renewable allows tokens to be renewed, defaults to true
+	Renewable *bool `json:"renewable,omitempty"`
+	//TokenPeriod makes tokens periodic with the given renewal period
+	TokenPeriod string `json:"token_period,omitempty"`
+	//TokenBoundCIDRs is the list of CIDRs tokens can be used from
+	TokenBoundCIDRs     []string `json:"token_bound_cidrs,omitempty"`
+	TokenExplicitMaxTTL string   `json:"token_explicit_max_ttl,omitempty"`
+	//PathSuffix is appended to the token paths, useful for revoking tokens by prefix
+	PathSuffix string `json:"path_suffix,omitempty"`
+}
+
+// TokenRoleStatus defines the observed state of TokenRole
+type TokenRoleStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TokenRole is the Schema for the tokenroles API
+type TokenRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *TokenRoleSpec   `json:"spec,omitempty"`
+	Status *TokenRoleStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (t *TokenRole) IsBeingDeleted() bool {
+	return !t.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a token role has been created
+func (t *TokenRole) IsCreated() bool {
+	if t.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (t *TokenRole) HasFinalizer(name string) bool {
+	return containsString(t.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (t *TokenRole) AddFinalizer(name string) {
+	t.ObjectMeta.Finalizers = append(t.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (t *TokenRole) RemoveFinalizer(name string) {
+	t.ObjectMeta.Finalizers = removeString(t.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (t *TokenRole) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(t.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// TokenRoleList contains a list of TokenRole
+type TokenRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TokenRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TokenRole{}, &TokenRoleList{})
+}
@@ -0,0 +1,144 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//TransitKeyFinalizer name of the transitkey finalizer
+	TransitKeyFinalizer = "transitkey.finalizers.vault.gobins.github.io"
+	//TransitKeyFailedState state when failed
+	TransitKeyFailedState = "failed"
+	//TransitKeyCreatedState state when created
+	TransitKeyCreatedState = "created"
+	//TransitKeyUpdatedState state when updated
+	TransitKeyUpdatedState = "updated"
+	//TransitKeyDeletionPolicyDelete deletes the key when the object is deleted
+	TransitKeyDeletionPolicyDelete = "Delete"
+	//TransitKeyDeletionPolicyRetain keeps the key when the object is deleted
+	TransitKeyDeletionPolicyRetain = "Retain"
+)
+
+// TransitKeySpec defines the desired state of TransitKey
+type TransitKeySpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type transit holding the key
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the transit mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Name is the key name
+	Name string `json:"name"`
+	//Type is the key type, it cannot be changed once the key is created
+	// +kubebuilder:validation:Enum=aes128-gcm96;aes256-gcm96;chacha20-poly1305;ed25519;ecdsa-p256;ecdsa-p384;ecdsa-p521;rsa-2048;rsa-3072;rsa-4096
+	Type string `json:"type,omitempty"`
+	//Exportable allows the key to be exported, it cannot be disabled once enabled
+	Exportable bool `json:"exportable,omitempty"`
+	//AllowPlaintextBackup allows a plaintext backup of the key, it cannot be disabled once enabled
+	AllowPlaintextBackup bool `json:"allow_plaintext_backup,omitempty"`
+	//DeletionAllowed allows the key to be deleted from vault
+	DeletionAllowed      bool `json:"deletion_allowed,omitempty"`
+	MinDecryptionVersion int  `json:"min_decryption_version,omitempty"`
+	MinEncryptionVersion int  `json:"min_encryption_version,omitempty"`
+	//AutoRotatePeriod is the period after which vault rotates the key itself
+	AutoRotatePeriod string `json:"auto_rotate_period,omitempty"`
+	//RotationSchedule is a five field cron expression, in UTC, on which the controller rotates the key
+	RotationSchedule string `json:"rotation_schedule,omitempty"`
+	//DeletionPolicy is either Delete to delete the key along with the object or Retain to keep it
+	// +kubebuilder:validation:Enum=Delete;Retain
+	DeletionPolicy string `json:"deletion_policy,omitempty"`
+}
+
+// TransitKeyStatus defines the observed state of TransitKey
+type TransitKeyStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the transit mount path of the key
+	Path string `json:"path,omitempty"`
+	//LatestVersion is the latest version of the key
+	LatestVersion int64 `json:"latest_version,omitempty"`
+	//LastRotated is the time the controller last rotated the key
+	LastRotated *metav1.Time `json:"last_rotated,omitempty"`
+	//NextRotation is the next time the controller rotates the key
+	NextRotation *metav1.Time `json:"next_rotation,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TransitKey is the Schema for the transitkeys API
+type TransitKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *TransitKeySpec   `json:"spec,omitempty"`
+	Status *TransitKeyStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (t *TransitKey) IsBeingDeleted() bool {
+	return !t.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a transit key has been created
+func (t *TransitKey) IsCreated() bool {
+	if t.Status == nil {
+		return false
+	}
+	return true
+}
+
+// IsRetained returns true if the key must be kept when the object is deleted
+func (t *TransitKey) IsRetained() bool {
+	return t.Spec != nil && t.Spec.DeletionPolicy == TransitKeyDeletionPolicyRetain
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (t *TransitKey) HasFinalizer(name string) bool {
+	return containsString(t.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (t *TransitKey) AddFinalizer(name string) {
+	t.ObjectMeta.Finalizers = append(t.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (t *TransitKey) RemoveFinalizer(name string) {
+	t.ObjectMeta.Finalizers = removeString(t.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (t *TransitKey) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(t.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// TransitKeyList contains a list of TransitKey
+type TransitKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TransitKey `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TransitKey{}, &TransitKeyList{})
+}
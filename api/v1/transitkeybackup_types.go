@@ -0,0 +1,169 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//TransitKeyBackupFailedState state when failed
+	TransitKeyBackupFailedState = "failed"
+	//TransitKeyBackupCreatedState state when created
+	TransitKeyBackupCreatedState = "created"
+	//TransitKeyBackupUpdatedState state when updated
+	TransitKeyBackupUpdatedState = "updated"
+	//TransitKeyBackupRestoredState state when the key was restored
+	TransitKeyBackupRestoredState = "restored"
+	//TransitKeyBackupBackupMode backs the key up into Secrets
+	TransitKeyBackupBackupMode = "backup"
+	//TransitKeyBackupRestoreMode restores the key from a backup Secret
+	TransitKeyBackupRestoreMode = "restore"
+	//TransitKeyBackupLabel is the label holding the secret_name prefix on backup Secrets
+	TransitKeyBackupLabel = "vault.gobins.github.io/transitkeybackup"
+	//TransitKeyBackupOwnerLabel is the label holding the name of the TransitKeyBackup which created a backup Secret
+	TransitKeyBackupOwnerLabel = "vault.gobins.github.io/transitkeybackup-owner"
+	//TransitKeyBackupDefaultGenerations is the default number of backup Secrets kept
+	TransitKeyBackupDefaultGenerations = 3
+)
+
+// TransitKeyBackupSpec defines the desired state of TransitKeyBackup
+type TransitKeyBackupSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type transit holding the key
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the transit mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Name is the key name
+	Name string `json:"name"`
+	//Mode is either backup to back the key up into Secrets or restore to restore it from one, defaults to backup
+	// +kubebuilder:validation:Enum=backup;restore
+	Mode string `json:"mode,omitempty"`
+	//Schedule is a five field cron expression, in UTC, on which the key is backed up, a single backup is taken when unset
+	Schedule string `json:"schedule,omitempty"`
+	//SecretName is the prefix of the backup Secrets, defaults to the object name
+	SecretName string `json:"secret_name,omitempty"`
+	//Generations is the number of backup Secrets to keep, defaults to 3
+	// +kubebuilder:validation:Minimum=1
+	Generations int `json:"generations,omitempty"`
+	//RestoreFrom is the name of the backup Secret to restore, defaults to the latest generation
+	RestoreFrom string `json:"restore_from,omitempty"`
+	//TargetConfig is the name of the vault config ConfigMap the key is restored into, defaults to config
+	TargetConfig string `json:"target_config,omitempty"`
+	//Force overwrites an existing key on restore
+	Force bool `json:"force,omitempty"`
+}
+
+// Validate returns an error if the backup cannot be run
+func (s *TransitKeyBackupSpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Mode != "" && s.Mode != TransitKeyBackupBackupMode && s.Mode != TransitKeyBackupRestoreMode {
+		return fmt.Errorf("mode must be backup or restore")
+	}
+	if s.Generations < 0 {
+		return fmt.Errorf("generations must be positive")
+	}
+	return nil
+}
+
+// TransitKeyBackupStatus defines the observed state of TransitKeyBackup
+type TransitKeyBackupStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the transit mount path of the key
+	Path string `json:"path,omitempty"`
+	//LastBackup is the time of the last backup
+	LastBackup *metav1.Time `json:"last_backup,omitempty"`
+	//NextBackup is the time of the next scheduled backup
+	NextBackup *metav1.Time `json:"next_backup,omitempty"`
+	//KeyVersion is the latest key version of the last backup or restore
+	KeyVersion int64 `json:"key_version,omitempty"`
+	//Secrets is the list of backup Secrets kept, oldest first
+	Secrets []string `json:"secrets,omitempty"`
+	//RestoredFrom is the name of the backup Secret last restored
+	RestoredFrom string `json:"restored_from,omitempty"`
+	//LastRestore is the time of the last restore
+	LastRestore *metav1.Time `json:"last_restore,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TransitKeyBackup is the Schema for the transitkeybackups API
+type TransitKeyBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *TransitKeyBackupSpec   `json:"spec,omitempty"`
+	Status *TransitKeyBackupStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (t *TransitKeyBackup) IsBeingDeleted() bool {
+	return !t.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a transit key backup has been created
+func (t *TransitKeyBackup) IsCreated() bool {
+	if t.Status == nil {
+		return false
+	}
+	return true
+}
+
+// IsRestore returns true if the object restores a key
+func (t *TransitKeyBackup) IsRestore() bool {
+	return t.Spec.Mode == TransitKeyBackupRestoreMode
+}
+
+// GetSecretName returns the prefix of the backup Secrets
+func (t *TransitKeyBackup) GetSecretName() string {
+	if t.Spec.SecretName != "" {
+		return t.Spec.SecretName
+	}
+	return t.GetName()
+}
+
+// GetGenerations returns the number of backup Secrets to keep
+func (t *TransitKeyBackup) GetGenerations() int {
+	if t.Spec.Generations > 0 {
+		return t.Spec.Generations
+	}
+	return TransitKeyBackupDefaultGenerations
+}
+
+// GetHash returns a hash of the struct
+func (t *TransitKeyBackup) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(t.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// TransitKeyBackupList contains a list of TransitKeyBackup
+type TransitKeyBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TransitKeyBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TransitKeyBackup{}, &TransitKeyBackupList{})
+}
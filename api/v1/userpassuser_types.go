@@ -0,0 +1,117 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//UserpassUserFinalizer name of the userpassuser finalizer
+	UserpassUserFinalizer = "userpassuser.finalizers.vault.gobins.github.io"
+	//UserpassUserFailedState state when failed
+	UserpassUserFailedState = "failed"
+	//UserpassUserCreatedState state when created
+	UserpassUserCreatedState = "created"
+	//UserpassUserUpdatedState state when updated
+	UserpassUserUpdatedState = "updated"
+)
+
+// UserpassUserSpec defines the desired state of UserpassUser
+type UserpassUserSpec struct {
+	//SysAuthRef is the name of the SysAuth of type userpass holding the user
+	SysAuthRef string `json:"sysauth_ref"`
+	//Name is the username
+	Name string `json:"name"`
+	//Password references the secret key holding the user password
+	Password SecretKeyReference `json:"password"`
+	//TokenPolicies is the list of vault policy names attached to issued tokens
+	TokenPolicies []string `json:"token_policies,omitempty"`
+	TokenTTL      string   `json:"token_ttl,omitempty"`
+	TokenMaxTTL   string   `json:"token_max_ttl,omitempty"`
+}
+
+// UserpassUserStatus defines the observed state of UserpassUser
+type UserpassUserStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the auth mount path the user was written to
+	Path string `json:"path,omitempty"`
+	//PasswordVersion is the resource version of the password secret pushed to vault
+	PasswordVersion string `json:"password_version,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UserpassUser is the Schema for the userpassusers API
+type UserpassUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *UserpassUserSpec   `json:"spec,omitempty"`
+	Status *UserpassUserStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (u *UserpassUser) IsBeingDeleted() bool {
+	return !u.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a userpass user has been created
+func (u *UserpassUser) IsCreated() bool {
+	if u.Status == nil {
+		return false
+	}
+	return true
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (u *UserpassUser) HasFinalizer(name string) bool {
+	return containsString(u.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (u *UserpassUser) AddFinalizer(name string) {
+	u.ObjectMeta.Finalizers = append(u.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (u *UserpassUser) RemoveFinalizer(name string) {
+	u.ObjectMeta.Finalizers = removeString(u.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (u *UserpassUser) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(u.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// UserpassUserList contains a list of UserpassUser
+type UserpassUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UserpassUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UserpassUser{}, &UserpassUserList{})
+}
@@ -1,5 +1,10 @@
 package v1
 
+import (
+	"fmt"
+	"net/url"
+)
+
 func containsString(array []string, input string) bool {
 	for _, item := range array {
 		if item == input {
@@ -18,3 +23,14 @@ func removeString(array []string, input string) (result []string) {
 	}
 	return result
 }
+
+func validateURL(input string) error {
+	u, err := url.Parse(input)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s is not an absolute URL", input)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//VaultCertificateFailedState state when failed
+	VaultCertificateFailedState = "failed"
+	//VaultCertificateIssuedState state when a certificate was issued
+	VaultCertificateIssuedState = "issued"
+	//VaultCertificateRenewedState state when the certificate was renewed
+	VaultCertificateRenewedState = "renewed"
+	//VaultCertificateDefaultRenewAtPercent is the default share of the lifetime after which a certificate is renewed
+	VaultCertificateDefaultRenewAtPercent = 66
+)
+
+// VaultCertificateSpec defines the desired state of VaultCertificate
+type VaultCertificateSpec struct {
+	//SecretsEngineRef is the name of the SecretsEngine of type pki issuing the certificate
+	SecretsEngineRef string `json:"secretsengine_ref,omitempty"`
+	//Mount is the pki mount path, used when secretsengine_ref is unset
+	Mount string `json:"mount,omitempty"`
+	//Role is the pki role used to issue the certificate
+	Role       string   `json:"role"`
+	CommonName string   `json:"common_name"`
+	AltNames   []string `json:"alt_names,omitempty"`
+	IPSANs     []string `json:"ip_sans,omitempty"`
+	URISANs    []string `json:"uri_sans,omitempty"`
+	TTL        string   `json:"ttl,omitempty"`
+	//SecretName is the name of the kubernetes.io/tls Secret, defaults to the object name
+	SecretName string `json:"secret_name,omitempty"`
+	//RenewAtPercent is the share of the certificate lifetime after which it is renewed, defaults to 66
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	RenewAtPercent int `json:"renew_at_percent,omitempty"`
+	//RevokePrevious revokes the serial of the replaced certificate after a renewal
+	RevokePrevious bool `json:"revoke_previous,omitempty"`
+}
+
+// Validate returns an error if the certificate cannot be issued
+func (s *VaultCertificateSpec) Validate() error {
+	if s.Role == "" || s.CommonName == "" {
+		return fmt.Errorf("role and common_name are required")
+	}
+	if s.RenewAtPercent < 0 || s.RenewAtPercent > 99 {
+		return fmt.Errorf("renew_at_percent must be between 1 and 99")
+	}
+	return nil
+}
+
+// VaultCertificateStatus defines the observed state of VaultCertificate
+type VaultCertificateStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//Path is the pki mount path the certificate was issued by
+	Path string `json:"path,omitempty"`
+	//SecretName is the name of the Secret written
+	SecretName   string `json:"secret_name,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	//NotAfter is the expiry of the certificate
+	NotAfter *metav1.Time `json:"not_after,omitempty"`
+	//RenewalTime is the time the certificate will be renewed
+	RenewalTime *metav1.Time `json:"renewal_time,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VaultCertificate is the Schema for the vaultcertificates API
+type VaultCertificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *VaultCertificateSpec   `json:"spec,omitempty"`
+	Status *VaultCertificateStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (v *VaultCertificate) IsBeingDeleted() bool {
+	return !v.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if a certificate has been issued
+func (v *VaultCertificate) IsCreated() bool {
+	if v.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetSecretName returns the name of the Secret to write
+func (v *VaultCertificate) GetSecretName() string {
+	if v.Spec.SecretName != "" {
+		return v.Spec.SecretName
+	}
+	return v.GetName()
+}
+
+// GetHash returns a hash of the struct
+func (v *VaultCertificate) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(v.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// VaultCertificateList contains a list of VaultCertificate
+type VaultCertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VaultCertificate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VaultCertificate{}, &VaultCertificateList{})
+}
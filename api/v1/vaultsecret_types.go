@@ -0,0 +1,192 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/mitchellh/hashstructure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//VaultSecretFinalizer name of the vaultsecret finalizer
+	VaultSecretFinalizer = "vaultsecret.finalizers.vault.gobins.github.io"
+	//VaultSecretFailedState state when failed
+	VaultSecretFailedState = "failed"
+	//VaultSecretCreatedState state when created
+	VaultSecretCreatedState = "created"
+	//VaultSecretUpdatedState state when updated
+	VaultSecretUpdatedState = "updated"
+	//VaultSecretDeletionPolicyDelete deletes the Secret along with the object
+	VaultSecretDeletionPolicyDelete = "Delete"
+	//VaultSecretDeletionPolicyOrphan keeps the Secret when the object is deleted
+	VaultSecretDeletionPolicyOrphan = "Orphan"
+)
+
+// VaultSecretSpec defines the desired state of VaultSecret
+type VaultSecretSpec struct {
+	//Mount is the path of the KV secrets engine
+	Mount string `json:"mount"`
+	//Path is the secret path within the mount
+	Path string `json:"path"`
+	//KVVersion is the version of the KV secrets engine, defaults to 2
+	// +kubebuilder:validation:Enum=1;2
+	KVVersion int `json:"kv_version,omitempty"`
+	//Version pins the KV v2 secret version, the latest version is synced when unset
+	Version int `json:"version,omitempty"`
+	//SecretName is the name of the created Secret, defaults to the object name
+	SecretName string `json:"secret_name,omitempty"`
+	//Keys maps the Secret keys to KV keys or templates, all KV keys are copied when empty
+	Keys []VaultSecretKey `json:"keys,omitempty"`
+	//RefreshInterval is how often the KV secret is read again, defaults to 5m
+	RefreshInterval string `json:"refresh_interval,omitempty"`
+	//DeletionPolicy is either Delete to remove the Secret along with the object or Orphan to keep it
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	DeletionPolicy string `json:"deletion_policy,omitempty"`
+}
+
+// VaultSecretKey defines a key of the created Secret
+type VaultSecretKey struct {
+	//Name is the key in the Secret
+	Name string `json:"name"`
+	//From is the KV key copied into the Secret key
+	From string `json:"from,omitempty"`
+	//Template is a go template rendered with the KV data, such as {{ .username }}:{{ .password }}
+	Template string `json:"template,omitempty"`
+}
+
+// Validate returns an error if the spec cannot be synced
+func (s *VaultSecretSpec) Validate() error {
+	if s.Mount == "" || s.Path == "" {
+		return fmt.Errorf("mount and path are required")
+	}
+	if s.Version != 0 && s.KVVersion == 1 {
+		return fmt.Errorf("version is only supported by kv version 2")
+	}
+	if s.RefreshInterval != "" {
+		if _, err := time.ParseDuration(s.RefreshInterval); err != nil {
+			return fmt.Errorf("invalid refresh_interval: %v", err)
+		}
+	}
+	return validateSecretKeys(s.Keys)
+}
+
+// validateSecretKeys returns an error if the Secret keys cannot be rendered
+func validateSecretKeys(keys []VaultSecretKey) error {
+	names := map[string]bool{}
+	for _, key := range keys {
+		if key.Name == "" {
+			return fmt.Errorf("keys require a name")
+		}
+		if names[key.Name] {
+			return fmt.Errorf("key %s is defined twice", key.Name)
+		}
+		names[key.Name] = true
+		if (key.From == "") == (key.Template == "") {
+			return fmt.Errorf("key %s requires one of from or template", key.Name)
+		}
+		if key.Template != "" {
+			if _, err := template.New(key.Name).Parse(key.Template); err != nil {
+				return fmt.Errorf("invalid template for key %s: %v", key.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// VaultSecretStatus defines the observed state of VaultSecret
+type VaultSecretStatus struct {
+	Hash  string `json:"hash,omitempty"`
+	State string `json:"state,omitempty"`
+	//SecretName is the name of the Secret written
+	SecretName string `json:"secret_name,omitempty"`
+	//Version is the KV v2 version written to the Secret
+	Version int64 `json:"version,omitempty"`
+	//DataHash is a hash of the data written to the Secret
+	DataHash string `json:"data_hash,omitempty"`
+	//LastSynced is the time the Secret was last written
+	LastSynced *metav1.Time `json:"last_synced,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VaultSecret is the Schema for the vaultsecrets API
+type VaultSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   *VaultSecretSpec   `json:"spec,omitempty"`
+	Status *VaultSecretStatus `json:"status,omitempty"`
+}
+
+// IsBeingDeleted returns true if a deletion timestamp is set
+func (v *VaultSecret) IsBeingDeleted() bool {
+	return !v.ObjectMeta.DeletionTimestamp.IsZero()
+}
+
+// IsCreated returns true if the Secret has been written
+func (v *VaultSecret) IsCreated() bool {
+	if v.Status == nil {
+		return false
+	}
+	return true
+}
+
+// GetSecretName returns the name of the Secret to write
+func (v *VaultSecret) GetSecretName() string {
+	if v.Spec.SecretName != "" {
+		return v.Spec.SecretName
+	}
+	return v.GetName()
+}
+
+// HasFinalizer returns true if item has a finalizer with input name
+func (v *VaultSecret) HasFinalizer(name string) bool {
+	return containsString(v.ObjectMeta.Finalizers, name)
+}
+
+// AddFinalizer adds the input finalizer
+func (v *VaultSecret) AddFinalizer(name string) {
+	v.ObjectMeta.Finalizers = append(v.ObjectMeta.Finalizers, name)
+}
+
+// RemoveFinalizer removes the input finalizer
+func (v *VaultSecret) RemoveFinalizer(name string) {
+	v.ObjectMeta.Finalizers = removeString(v.ObjectMeta.Finalizers, name)
+}
+
+// GetHash returns a hash of the struct
+func (v *VaultSecret) GetHash() (string, error) {
+	hash, err := hashstructure.Hash(v.Spec, nil)
+	return fmt.Sprintf("%d", hash), err
+}
+
+// +kubebuilder:object:root=true
+
+// VaultSecretList contains a list of VaultSecret
+type VaultSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VaultSecret `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VaultSecret{}, &VaultSecretList{})
+}
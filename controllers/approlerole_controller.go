@@ -0,0 +1,406 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// secretIDAccessorAnnotation records on the target Secret the accessor of the secret_id it holds
+const secretIDAccessorAnnotation = "vault.gobins.github.io/secret-id-accessor"
+
+// AppRoleRoleReconciler reconciles a AppRoleRole object
+type AppRoleRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=approleroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=approleroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=policies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *AppRoleRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("approlerole", req.NamespacedName)
+
+	role := &apiv1.AppRoleRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	policies, missing, err := resolvePolicies(r.Client, role.GetNamespace(), role.Spec.TokenPolicies, role.Spec.PolicyRefs)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to resolve policy refs: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when resolving policy refs: %v", err)
+	}
+	if len(missing) > 0 {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("policy refs not ready: %s", strings.Join(missing, ", ")))
+	}
+
+	isUptoDate, err := r.IsUptoDate(role, policies, missing)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking approlerole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating approle role %v", role.Spec.Name))
+		if err := r.put(role, policies, missing); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating approlerole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.AppRoleRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "approle role is updated")
+	}
+
+	var requeueAfter time.Duration
+	if role.Spec.SecretIDTarget == nil {
+		if err := r.retireSecretID(role); err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to retire secret_id: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when retiring secret_id: %v", err)
+		}
+	} else {
+		requeueAfter, err = r.deliverSecretID(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to deliver secret_id: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when delivering secret_id: %v", err)
+		}
+	}
+	if err := r.revokeSecretIDs(role); err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to revoke retired secret_id: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when revoking retired secret_id: %v", err)
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *AppRoleRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *AppRoleRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.AppRoleRole{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+func (r *AppRoleRoleReconciler) delete(a *apiv1.AppRoleRole) error {
+	r.Log.Info(fmt.Sprintf("deleting approle role %s", a.GetName()))
+	if a.Status == nil || a.Status.Path == "" {
+		return nil
+	}
+	for _, accessor := range append(a.Status.RetiredSecretIDAccessors, a.Status.SecretIDAccessor) {
+		if err := r.destroySecretID(a.Status.Path, a.Spec.Name, accessor); err != nil {
+			return err
+		}
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/role/%s", a.Status.Path, a.Spec.Name))
+	return err
+}
+
+func (r *AppRoleRoleReconciler) put(a *apiv1.AppRoleRole, policies, missing []string) error {
+	path, err := getAuthMountPath(r.Client, a.GetNamespace(), a.Spec.SysAuthRef, "approle")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"secret_id_bound_cidrs": a.Spec.SecretIDBoundCIDRs,
+		"token_bound_cidrs":     a.Spec.TokenBoundCIDRs,
+		"token_policies":        policies,
+		"token_ttl":             a.Spec.TokenTTL,
+		"token_max_ttl":         a.Spec.TokenMaxTTL,
+		"secret_id_ttl":         a.Spec.SecretIDTTL,
+		"secret_id_num_uses":    a.Spec.SecretIDNumUses,
+	}
+	if a.Spec.BindSecretID != nil {
+		data["bind_secret_id"] = *a.Spec.BindSecretID
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s", path, a.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := a.GetHash()
+	if err != nil {
+		return err
+	}
+	status := &apiv1.AppRoleRoleStatus{}
+	if a.Status != nil {
+		status = a.Status.DeepCopy()
+	}
+	status.Hash = hash
+	status.State = apiv1.AppRoleRoleUpdatedState
+	if !a.IsCreated() {
+		status.State = apiv1.AppRoleRoleCreatedState
+	}
+	status.Path = path
+	status.Policies = policies
+	status.MissingPolicyRefs = missing
+	a.Status = status
+	return r.Update(context.Background(), a)
+}
+
+// deliverSecretID writes the role_id and a secret_id into the target Secret, rotating the
+// secret_id before it expires, and returns the time until the next rotation
+func (r *AppRoleRoleReconciler) deliverSecretID(a *apiv1.AppRoleRole) (time.Duration, error) {
+	target := a.Spec.SecretIDTarget
+	rotateBefore, err := durationSeconds(target.RotateBefore)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rotate_before: %v", err)
+	}
+	secret := &corev1.Secret{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: target.SecretName, Namespace: a.GetNamespace()}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	exists := err == nil
+	if exists && !metav1.IsControlledBy(secret, a) {
+		return 0, fmt.Errorf("%s already exists and is not owned by %s, refusing to overwrite it", target.SecretName, a.GetName())
+	}
+
+	if exists && a.Status.SecretIDAccessor != "" && secret.Annotations[secretIDAccessorAnnotation] == a.Status.SecretIDAccessor {
+		if a.Status.SecretIDExpiry == nil {
+			return 0, nil
+		}
+		rotateAt := rotationTime(a.Status.SecretIDExpiry.Time, a.Status.SecretIDTTL, rotateBefore)
+		if wait := time.Until(rotateAt); wait > 0 {
+			return wait, nil
+		}
+	}
+
+	path := a.Status.Path
+	roleIDResp, err := r.APIClient.Logical().Read(fmt.Sprintf("auth/%s/role/%s/role-id", path, a.Spec.Name))
+	if err != nil {
+		return 0, err
+	}
+	if roleIDResp == nil {
+		return 0, fmt.Errorf("role %s not found", a.Spec.Name)
+	}
+	roleID, _ := roleIDResp.Data["role_id"].(string)
+
+	secretIDResp, err := r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s/secret-id", path, a.Spec.Name), nil)
+	if err != nil {
+		return 0, err
+	}
+	if secretIDResp == nil {
+		return 0, fmt.Errorf("empty secret_id response for role %s", a.Spec.Name)
+	}
+	secretID, _ := secretIDResp.Data["secret_id"].(string)
+	accessor, _ := secretIDResp.Data["secret_id_accessor"].(string)
+	ttl, err := toInt64(secretIDResp.Data["secret_id_ttl"])
+	if err != nil {
+		return 0, err
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      target.SecretName,
+			Namespace: a.GetNamespace(),
+		},
+	}
+	err = writeOwned(r.Client, r.Scheme, a, secret, func() {
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[secretIDAccessorAnnotation] = accessor
+		secret.Data = map[string][]byte{
+			"role_id":   []byte(roleID),
+			"secret_id": []byte(secretID),
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.Recorder.Event(a, corev1.EventTypeNormal, "rotated", fmt.Sprintf("secret_id is written to secret %s", target.SecretName))
+
+	if a.Status.SecretIDAccessor != "" {
+		a.Status.RetiredSecretIDAccessors = append(a.Status.RetiredSecretIDAccessors, a.Status.SecretIDAccessor)
+	}
+	a.Status.RoleID = roleID
+	a.Status.SecretIDAccessor = accessor
+	a.Status.SecretIDTTL = ttl
+	a.Status.SecretIDExpiry = nil
+	if ttl > 0 {
+		expiry := metav1.NewTime(time.Now().Add(time.Duration(ttl) * time.Second))
+		a.Status.SecretIDExpiry = &expiry
+	}
+	if err := r.Update(context.Background(), a); err != nil {
+		return 0, err
+	}
+
+	if a.Status.SecretIDExpiry == nil {
+		return 0, nil
+	}
+	return time.Until(rotationTime(a.Status.SecretIDExpiry.Time, ttl, rotateBefore)), nil
+}
+
+// retireSecretID marks the delivered secret_id for revocation once the target is removed
+func (r *AppRoleRoleReconciler) retireSecretID(a *apiv1.AppRoleRole) error {
+	if a.Status == nil || a.Status.SecretIDAccessor == "" {
+		return nil
+	}
+	a.Status.RetiredSecretIDAccessors = append(a.Status.RetiredSecretIDAccessors, a.Status.SecretIDAccessor)
+	a.Status.SecretIDAccessor = ""
+	a.Status.SecretIDExpiry = nil
+	a.Status.SecretIDTTL = 0
+	return r.Update(context.Background(), a)
+}
+
+// revokeSecretIDs destroys the retired secret_ids, keeping in status the accessors which
+// could not be revoked so that they are retried
+func (r *AppRoleRoleReconciler) revokeSecretIDs(a *apiv1.AppRoleRole) error {
+	if a.Status == nil || len(a.Status.RetiredSecretIDAccessors) == 0 {
+		return nil
+	}
+	var remaining []string
+	var revokeErr error
+	for _, accessor := range a.Status.RetiredSecretIDAccessors {
+		if err := r.destroySecretID(a.Status.Path, a.Spec.Name, accessor); err != nil {
+			remaining = append(remaining, accessor)
+			if revokeErr == nil {
+				revokeErr = err
+			}
+		}
+	}
+	if len(remaining) != len(a.Status.RetiredSecretIDAccessors) {
+		a.Status.RetiredSecretIDAccessors = remaining
+		if err := r.Update(context.Background(), a); err != nil {
+			return err
+		}
+	}
+	return revokeErr
+}
+
+// destroySecretID revokes a secret_id by its accessor
+func (r *AppRoleRoleReconciler) destroySecretID(path, role, accessor string) error {
+	if accessor == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s/secret-id-accessor/destroy", path, role),
+		map[string]interface{}{
+			"secret_id_accessor": accessor,
+		})
+	return err
+}
+
+// rotationTime returns when a secret_id expiring at expiry with a lifetime of ttl seconds
+// should be rotated, rotateBefore seconds ahead or at two thirds of its lifetime. rotateBefore
+// is capped at half the lifetime so that a fresh secret_id is never due for rotation at once
+func rotationTime(expiry time.Time, ttl int64, rotateBefore int) time.Time {
+	if rotateBefore > 0 {
+		before := time.Duration(rotateBefore) * time.Second
+		if limit := time.Duration(ttl) * time.Second / 2; ttl > 0 && before > limit {
+			before = limit
+		}
+		return expiry.Add(-before)
+	}
+	return expiry.Add(-time.Duration(ttl) * time.Second / 3)
+}
+
+// IsUptoDate returns true if an approle role is current
+func (r *AppRoleRoleReconciler) IsUptoDate(a *apiv1.AppRoleRole, policies, missing []string) (bool, error) {
+	hash, err := a.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating approlerole hash: %v", err)
+	}
+	if a.Status == nil {
+		return false, nil
+	}
+	if a.Status.Hash != hash {
+		return false, nil
+	}
+	if !equalStrings(a.Status.Policies, policies) || !equalStrings(a.Status.MissingPolicyRefs, missing) {
+		return false, nil
+	}
+	return true, nil
+}
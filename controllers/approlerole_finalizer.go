@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *AppRoleRoleReconciler) addFinalizer(instance *apiv1.AppRoleRole) error {
+	instance.AddFinalizer(apiv1.AppRoleRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *AppRoleRoleReconciler) handleFinalizer(a *apiv1.AppRoleRole) error {
+	if !a.HasFinalizer(apiv1.AppRoleRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(a); err != nil {
+		return err
+	}
+	a.RemoveFinalizer(apiv1.AppRoleRoleFinalizer)
+	return r.Update(context.Background(), a)
+}
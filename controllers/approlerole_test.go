@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestAppRoleRoleRotateBeforeExceedsTTL(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	vault.set("auth/approle/role/dev/role-id", map[string]interface{}{"role_id": "role"})
+	vault.respond("auth/approle/role/dev/secret-id", map[string]interface{}{
+		"secret_id":          "secret",
+		"secret_id_accessor": "accessor",
+		"secret_id_ttl":      3600,
+	})
+	role := &apiv1.AppRoleRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.AppRoleRoleSpec{
+			SysAuthRef:     "approle",
+			Name:           "dev",
+			SecretIDTarget: &apiv1.AppRoleSecretIDTarget{SecretName: "dev-secret-id", RotateBefore: "2h"},
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSysAuth("approle", "approle"), role)
+	r := &AppRoleRoleReconciler{Client: c, Log: ctrl.Log, Scheme: newScheme(t), Recorder: record.NewFakeRecorder(20)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "dev", Namespace: apiv1.WatchNamespace}}
+	for i := 0; i < 2; i++ {
+		result, err := r.Reconcile(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.RequeueAfter < 29*time.Minute || result.RequeueAfter > 30*time.Minute {
+			t.Errorf("expected a rotation at half the ttl, got a requeue after %s", result.RequeueAfter)
+		}
+	}
+
+	if err := c.Get(context.Background(), req.NamespacedName, role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Status.RetiredSecretIDAccessors) != 0 {
+		t.Errorf("expected the secret_id to be kept, retired %v", role.Status.RetiredSecretIDAccessors)
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "dev-secret-id", Namespace: apiv1.WatchNamespace}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["secret_id"]) != "secret" {
+		t.Errorf("unexpected secret_id %q", secret.Data["secret_id"])
+	}
+}
+
+func TestAppRoleRoleSecretIDTargetNotOwned(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	vault.set("auth/approle/role/dev/role-id", map[string]interface{}{"role_id": "role"})
+	role := &apiv1.AppRoleRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.AppRoleRoleSpec{
+			SysAuthRef:     "approle",
+			Name:           "dev",
+			SecretIDTarget: &apiv1.AppRoleSecretIDTarget{SecretName: "dev-secret-id"},
+		},
+	}
+	existing := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-secret-id", Namespace: apiv1.WatchNamespace},
+		Data:       map[string][]byte{"secret_id": []byte("other")},
+	}
+	c := newFakeClient(t, vault, newCreatedSysAuth("approle", "approle"), role, existing)
+	r := &AppRoleRoleReconciler{Client: c, Log: ctrl.Log, Scheme: newScheme(t), Recorder: record.NewFakeRecorder(20)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "dev", Namespace: apiv1.WatchNamespace}}
+	if _, err := r.Reconcile(req); err == nil {
+		t.Fatal("expected an error for a Secret not owned by the role")
+	}
+	if _, ok := vault.get("auth/approle/role/dev/secret-id"); ok {
+		t.Error("expected no secret_id to be generated")
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "dev-secret-id", Namespace: apiv1.WatchNamespace}, existing); err != nil {
+		t.Fatal(err)
+	}
+	if string(existing.Data["secret_id"]) != "other" {
+		t.Error("expected the existing Secret to be left alone")
+	}
+}
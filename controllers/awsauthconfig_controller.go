@@ -0,0 +1,231 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// AWSAuthConfigReconciler reconciles a AWSAuthConfig object
+type AWSAuthConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=awsauthconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=awsauthconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *AWSAuthConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("awsauthconfig", req.NamespacedName)
+
+	authConfig := &apiv1.AWSAuthConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, authConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if authConfig.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(authConfig)
+		if err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	secrets, versions, err := r.getSecrets(authConfig)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get credentials: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting credentials: %v", err)
+	}
+	secretsVersion := strings.Join(versions, ",")
+
+	isUptoDate, err := r.IsUptoDate(authConfig, secretsVersion)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking awsauthconfig IsUptoDate: %v", err)
+	}
+
+	if !authConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing aws auth config for sysauth %v", authConfig.Spec.SysAuthRef))
+		if err := r.put(authConfig, secrets, secretsVersion); err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing awsauthconfig: %v", err)
+		}
+
+		if !authConfig.HasFinalizer(apiv1.AWSAuthConfigFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(authConfig); err != nil {
+				r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(authConfig, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "updated", "aws auth config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *AWSAuthConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *AWSAuthConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.AWSAuthConfig{}).
+		Complete(r)
+}
+
+func (r *AWSAuthConfigReconciler) delete(a *apiv1.AWSAuthConfig) error {
+	r.Log.Info(fmt.Sprintf("deleting aws auth config %s", a.GetName()))
+	if a.Status == nil || a.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/config/client", a.Status.Path))
+	return err
+}
+
+// getSecrets returns the credentials referenced by the spec keyed by vault parameter, along with the secret resource versions
+func (r *AWSAuthConfigReconciler) getSecrets(a *apiv1.AWSAuthConfig) (map[string]string, []string, error) {
+	values := map[string]string{}
+	var versions []string
+	if a.Spec.AccessKey != nil {
+		value, version, err := getSecretValue(r.Client, a.GetNamespace(), a.Spec.AccessKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["access_key"] = value
+		versions = append(versions, version)
+	}
+	if a.Spec.SecretKey != nil {
+		value, version, err := getSecretValue(r.Client, a.GetNamespace(), a.Spec.SecretKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["secret_key"] = value
+		versions = append(versions, version)
+	}
+	return values, versions, nil
+}
+
+func (r *AWSAuthConfigReconciler) put(a *apiv1.AWSAuthConfig, secrets map[string]string, secretsVersion string) error {
+	path, err := getAuthMountPath(r.Client, a.GetNamespace(), a.Spec.SysAuthRef, "aws")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"endpoint":                   a.Spec.Endpoint,
+		"iam_endpoint":               a.Spec.IAMEndpoint,
+		"sts_endpoint":               a.Spec.STSEndpoint,
+		"sts_region":                 a.Spec.STSRegion,
+		"iam_server_id_header_value": a.Spec.IAMServerIDHeaderValue,
+	}
+	for key, value := range secrets {
+		data[key] = value
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/config/client", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := a.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.AWSAuthConfigUpdatedState
+	if !a.IsCreated() {
+		state = apiv1.AWSAuthConfigCreatedState
+	}
+	a.Status = &apiv1.AWSAuthConfigStatus{
+		Hash:           hash,
+		State:          state,
+		Path:           path,
+		SecretsVersion: secretsVersion,
+	}
+	return r.Update(context.Background(), a)
+}
+
+// IsUptoDate returns true if a aws auth config is current
+func (r *AWSAuthConfigReconciler) IsUptoDate(a *apiv1.AWSAuthConfig, secretsVersion string) (bool, error) {
+	hash, err := a.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating awsauthconfig hash: %v", err)
+	}
+	if a.Status == nil {
+		return false, nil
+	}
+	if a.Status.Hash != hash || a.Status.SecretsVersion != secretsVersion {
+		return false, nil
+	}
+	return true, nil
+}
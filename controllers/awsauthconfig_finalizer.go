@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *AWSAuthConfigReconciler) addFinalizer(instance *apiv1.AWSAuthConfig) error {
+	instance.AddFinalizer(apiv1.AWSAuthConfigFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *AWSAuthConfigReconciler) handleFinalizer(a *apiv1.AWSAuthConfig) error {
+	if !a.HasFinalizer(apiv1.AWSAuthConfigFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(a); err != nil {
+		return err
+	}
+	a.RemoveFinalizer(apiv1.AWSAuthConfigFinalizer)
+	return r.Update(context.Background(), a)
+}
@@ -0,0 +1,204 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// AWSAuthRoleReconciler reconciles a AWSAuthRole object
+type AWSAuthRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=awsauthroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=awsauthroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *AWSAuthRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("awsauthrole", req.NamespacedName)
+
+	role := &apiv1.AWSAuthRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking awsauthrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating aws auth role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating awsauthrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.AWSAuthRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "aws auth role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *AWSAuthRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *AWSAuthRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.AWSAuthRole{}).
+		Complete(r)
+}
+
+func (r *AWSAuthRoleReconciler) delete(a *apiv1.AWSAuthRole) error {
+	r.Log.Info(fmt.Sprintf("deleting aws auth role %s", a.GetName()))
+	if a.Status == nil || a.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/role/%s", a.Status.Path, a.Spec.Name))
+	return err
+}
+
+func (r *AWSAuthRoleReconciler) put(a *apiv1.AWSAuthRole) error {
+	path, err := getAuthMountPath(r.Client, a.GetNamespace(), a.Spec.SysAuthRef, "aws")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"bound_iam_principal_arn": a.Spec.BoundIAMPrincipalARNs,
+		"bound_iam_role_arn":      a.Spec.BoundIAMRoleARNs,
+		"bound_account_id":        a.Spec.BoundAccountIDs,
+		"bound_region":            a.Spec.BoundRegions,
+		"bound_vpc_id":            a.Spec.BoundVPCIDs,
+		"token_policies":          a.Spec.TokenPolicies,
+		"token_ttl":               a.Spec.TokenTTL,
+		"token_max_ttl":           a.Spec.TokenMaxTTL,
+	}
+	if a.Spec.AuthType != "" {
+		data["auth_type"] = a.Spec.AuthType
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s", path, a.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := a.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.AWSAuthRoleUpdatedState
+	if !a.IsCreated() {
+		state = apiv1.AWSAuthRoleCreatedState
+	}
+	a.Status = &apiv1.AWSAuthRoleStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), a)
+}
+
+// IsUptoDate returns true if a aws auth role is current
+func (r *AWSAuthRoleReconciler) IsUptoDate(a *apiv1.AWSAuthRole) (bool, error) {
+	hash, err := a.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating awsauthrole hash: %v", err)
+	}
+	if a.Status == nil {
+		return false, nil
+	}
+	if a.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *AWSAuthRoleReconciler) addFinalizer(instance *apiv1.AWSAuthRole) error {
+	instance.AddFinalizer(apiv1.AWSAuthRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *AWSAuthRoleReconciler) handleFinalizer(a *apiv1.AWSAuthRole) error {
+	if !a.HasFinalizer(apiv1.AWSAuthRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(a); err != nil {
+		return err
+	}
+	a.RemoveFinalizer(apiv1.AWSAuthRoleFinalizer)
+	return r.Update(context.Background(), a)
+}
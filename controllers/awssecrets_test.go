@@ -0,0 +1,192 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestAWSSecretsConfigReconcile(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: apiv1.WatchNamespace},
+		Data: map[string][]byte{
+			"access_key": []byte("AKIA"),
+			"secret_key": []byte("secret"),
+		},
+	}
+	awsConfig := &apiv1.AWSSecretsConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.AWSSecretsConfigSpec{
+			SecretsEngineRef: "aws",
+			AccessKey:        &apiv1.SecretKeyReference{Name: "aws", Key: "access_key"},
+			SecretKey:        &apiv1.SecretKeyReference{Name: "aws", Key: "secret_key"},
+			Region:           "eu-west-1",
+			STSEndpoint:      "https://sts.eu-west-1.amazonaws.com",
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSecretsEngine("aws", "aws"), secret, awsConfig)
+	r := &AWSSecretsConfigReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "aws", Namespace: apiv1.WatchNamespace}}
+
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	data, ok := vault.get("aws/config/root")
+	if !ok {
+		t.Fatal("expected aws/config/root to be written")
+	}
+	expected := map[string]interface{}{
+		"access_key":   "AKIA",
+		"secret_key":   "secret",
+		"region":       "eu-west-1",
+		"sts_endpoint": "https://sts.eu-west-1.amazonaws.com",
+	}
+	for key, value := range expected {
+		if data[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, data[key])
+		}
+	}
+}
+
+func TestAWSSecretsRoleReconcile(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	role := &apiv1.AWSSecretsRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.AWSSecretsRoleSpec{
+			Mount:          "aws",
+			Name:           "deploy",
+			CredentialType: "iam_user",
+			PolicyARNs:     []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			PolicyDocument: &apiv1.IAMPolicyDocument{
+				Statement: []apiv1.IAMPolicyStatement{{
+					Sid:      "Artifacts",
+					Effect:   "Allow",
+					Action:   []string{"s3:GetObject", "s3:PutObject"},
+					Resource: []string{"arn:aws:s3:::artifacts/*"},
+					Condition: []apiv1.IAMPolicyCondition{
+						{Operator: "StringEquals", Key: "aws:RequestedRegion", Values: []string{"eu-west-1"}},
+					},
+				}},
+			},
+		},
+	}
+	c := newFakeClient(t, vault, role)
+	r := &AWSSecretsRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "deploy", Namespace: apiv1.WatchNamespace}}
+
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	data, ok := vault.get("aws/roles/deploy")
+	if !ok {
+		t.Fatal("expected aws/roles/deploy to be written")
+	}
+	if data["credential_type"] != "iam_user" {
+		t.Errorf("unexpected credential_type %v", data["credential_type"])
+	}
+	if !reflect.DeepEqual(data["policy_arns"], []interface{}{"arn:aws:iam::aws:policy/ReadOnlyAccess"}) {
+		t.Errorf("unexpected policy_arns %v", data["policy_arns"])
+	}
+	document, _ := data["policy_document"].(string)
+	var policy map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		t.Fatalf("policy_document is not valid JSON: %v", err)
+	}
+	expected := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []interface{}{map[string]interface{}{
+			"Sid":      "Artifacts",
+			"Effect":   "Allow",
+			"Action":   []interface{}{"s3:GetObject", "s3:PutObject"},
+			"Resource": []interface{}{"arn:aws:s3:::artifacts/*"},
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]interface{}{"aws:RequestedRegion": []interface{}{"eu-west-1"}},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(policy, expected) {
+		t.Errorf("unexpected policy_document %s", document)
+	}
+
+	if err := c.Get(context.TODO(), req.NamespacedName, role); err != nil {
+		t.Fatal(err)
+	}
+	now := metav1.Now()
+	role.SetDeletionTimestamp(&now)
+	if err := c.Update(context.TODO(), role); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error on delete: %v", err)
+	}
+	if !vault.deleted("aws/roles/deploy") {
+		t.Error("expected aws/roles/deploy to be deleted")
+	}
+}
+
+func TestAWSSecretsRoleInvalidPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement apiv1.IAMPolicyStatement
+	}{
+		{"effect", apiv1.IAMPolicyStatement{Effect: "Permit", Action: []string{"s3:*"}, Resource: []string{"*"}}},
+		{"no action", apiv1.IAMPolicyStatement{Effect: "Allow", Resource: []string{"*"}}},
+		{"action and not_action", apiv1.IAMPolicyStatement{Effect: "Allow", Action: []string{"s3:*"}, NotAction: []string{"iam:*"}, Resource: []string{"*"}}},
+		{"action format", apiv1.IAMPolicyStatement{Effect: "Allow", Action: []string{"GetObject"}, Resource: []string{"*"}}},
+		{"resource format", apiv1.IAMPolicyStatement{Effect: "Allow", Action: []string{"s3:*"}, Resource: []string{"artifacts"}}},
+		{"condition", apiv1.IAMPolicyStatement{Effect: "Allow", Action: []string{"s3:*"}, Resource: []string{"*"}, Condition: []apiv1.IAMPolicyCondition{{Operator: "StringEquals"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := newFakeVault()
+			defer vault.Close()
+			role := &apiv1.AWSSecretsRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: apiv1.WatchNamespace},
+				Spec: &apiv1.AWSSecretsRoleSpec{
+					Mount:          "aws",
+					Name:           "deploy",
+					CredentialType: "federation_token",
+					PolicyDocument: &apiv1.IAMPolicyDocument{Statement: []apiv1.IAMPolicyStatement{tt.statement}},
+				},
+			}
+			c := newFakeClient(t, vault, role)
+			r := &AWSSecretsRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "deploy", Namespace: apiv1.WatchNamespace}}
+
+			if _, err := r.Reconcile(req); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			if _, ok := vault.get("aws/roles/deploy"); ok {
+				t.Error("expected an invalid policy not to be written")
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// AWSSecretsConfigReconciler reconciles a AWSSecretsConfig object
+type AWSSecretsConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=awssecretsconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=awssecretsconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *AWSSecretsConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("awssecretsconfig", req.NamespacedName)
+
+	cfg := &apiv1.AWSSecretsConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, cfg)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for <mount>/config/root, the config is removed along with the mount
+	if cfg.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(cfg, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(cfg, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	secrets, versions, err := r.getSecrets(cfg)
+	if err != nil {
+		r.Recorder.Event(cfg, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get credentials: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting credentials: %v", err)
+	}
+	secretsVersion := strings.Join(versions, ",")
+
+	isUptoDate, err := r.IsUptoDate(cfg, secretsVersion)
+	if err != nil {
+		r.Recorder.Event(cfg, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking awssecretsconfig IsUptoDate: %v", err)
+	}
+
+	if !cfg.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing aws secrets engine config %v", req.NamespacedName))
+		if err := r.put(cfg, secrets, secretsVersion); err != nil {
+			r.Recorder.Event(cfg, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing awssecretsconfig: %v", err)
+		}
+		r.Recorder.Event(cfg, corev1.EventTypeNormal, "updated", "aws secrets engine config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *AWSSecretsConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *AWSSecretsConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.AWSSecretsConfig{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretToConfigs),
+		}).
+		Complete(r)
+}
+
+// secretToConfigs enqueues the configs whose aws credentials are held by a Secret
+func (r *AWSSecretsConfigReconciler) secretToConfigs(o handler.MapObject) []reconcile.Request {
+	configs := &apiv1.AWSSecretsConfigList{}
+	if err := r.List(context.Background(), configs, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list aws secrets configs")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, config := range configs.Items {
+		if config.Spec == nil {
+			continue
+		}
+		for _, ref := range []*apiv1.SecretKeyReference{config.Spec.AccessKey, config.Spec.SecretKey} {
+			if ref != nil && ref.Name == o.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: config.GetName(), Namespace: config.GetNamespace()},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// getSecrets returns the credentials referenced by the spec keyed by vault parameter, along with the secret resource versions
+func (r *AWSSecretsConfigReconciler) getSecrets(a *apiv1.AWSSecretsConfig) (map[string]string, []string, error) {
+	values := map[string]string{}
+	var versions []string
+	if a.Spec.AccessKey != nil {
+		value, version, err := getSecretValue(r.Client, a.GetNamespace(), a.Spec.AccessKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["access_key"] = value
+		versions = append(versions, version)
+	}
+	if a.Spec.SecretKey != nil {
+		value, version, err := getSecretValue(r.Client, a.GetNamespace(), a.Spec.SecretKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["secret_key"] = value
+		versions = append(versions, version)
+	}
+	return values, versions, nil
+}
+
+func (r *AWSSecretsConfigReconciler) put(a *apiv1.AWSSecretsConfig, secrets map[string]string, secretsVersion string) error {
+	path, err := resolveMountPath(r.Client, a.GetNamespace(), a.Spec.SecretsEngineRef, a.Spec.Mount, "aws")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"region":       a.Spec.Region,
+		"iam_endpoint": a.Spec.IAMEndpoint,
+		"sts_endpoint": a.Spec.STSEndpoint,
+	}
+	for key, value := range secrets {
+		data[key] = value
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/config/root", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := a.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.AWSSecretsConfigUpdatedState
+	if !a.IsCreated() {
+		state = apiv1.AWSSecretsConfigCreatedState
+	}
+	a.Status = &apiv1.AWSSecretsConfigStatus{
+		Hash:           hash,
+		State:          state,
+		SecretsVersion: secretsVersion,
+	}
+	return r.Update(context.Background(), a)
+}
+
+// IsUptoDate returns true if a aws secrets engine config is current
+func (r *AWSSecretsConfigReconciler) IsUptoDate(a *apiv1.AWSSecretsConfig, secretsVersion string) (bool, error) {
+	hash, err := a.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating awssecretsconfig hash: %v", err)
+	}
+	if a.Status == nil {
+		return false, nil
+	}
+	if a.Status.Hash != hash || a.Status.SecretsVersion != secretsVersion {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *AWSSecretsRoleReconciler) addFinalizer(instance *apiv1.AWSSecretsRole) error {
+	instance.AddFinalizer(apiv1.AWSSecretsRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *AWSSecretsRoleReconciler) handleFinalizer(a *apiv1.AWSSecretsRole) error {
+	if !a.HasFinalizer(apiv1.AWSSecretsRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(a); err != nil {
+		return err
+	}
+	a.RemoveFinalizer(apiv1.AWSSecretsRoleFinalizer)
+	return r.Update(context.Background(), a)
+}
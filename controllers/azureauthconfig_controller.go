@@ -0,0 +1,197 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// AzureAuthConfigReconciler reconciles a AzureAuthConfig object
+type AzureAuthConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=azureauthconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=azureauthconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *AzureAuthConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("azureauthconfig", req.NamespacedName)
+
+	authConfig := &apiv1.AzureAuthConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, authConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for auth/<path>/config, the config is removed along with the mount
+	if authConfig.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	secrets, versions, err := r.getSecrets(authConfig)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get credentials: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting credentials: %v", err)
+	}
+	secretsVersion := strings.Join(versions, ",")
+
+	isUptoDate, err := r.IsUptoDate(authConfig, secretsVersion)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking azureauthconfig IsUptoDate: %v", err)
+	}
+
+	if !authConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing azure auth config for sysauth %v", authConfig.Spec.SysAuthRef))
+		if err := r.put(authConfig, secrets, secretsVersion); err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing azureauthconfig: %v", err)
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "updated", "azure auth config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *AzureAuthConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *AzureAuthConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.AzureAuthConfig{}).
+		Complete(r)
+}
+
+// getSecrets returns the credentials referenced by the spec keyed by vault parameter, along with the secret resource versions
+func (r *AzureAuthConfigReconciler) getSecrets(a *apiv1.AzureAuthConfig) (map[string]string, []string, error) {
+	values := map[string]string{}
+	var versions []string
+	if a.Spec.ClientSecret != nil {
+		value, version, err := getSecretValue(r.Client, a.GetNamespace(), a.Spec.ClientSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["client_secret"] = value
+		versions = append(versions, version)
+	}
+	return values, versions, nil
+}
+
+func (r *AzureAuthConfigReconciler) put(a *apiv1.AzureAuthConfig, secrets map[string]string, secretsVersion string) error {
+	path, err := getAuthMountPath(r.Client, a.GetNamespace(), a.Spec.SysAuthRef, "azure")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"tenant_id":   a.Spec.TenantID,
+		"resource":    a.Spec.Resource,
+		"environment": a.Spec.Environment,
+		"client_id":   a.Spec.ClientID,
+	}
+	for key, value := range secrets {
+		data[key] = value
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/config", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := a.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.AzureAuthConfigUpdatedState
+	if !a.IsCreated() {
+		state = apiv1.AzureAuthConfigCreatedState
+	}
+	a.Status = &apiv1.AzureAuthConfigStatus{
+		Hash:           hash,
+		State:          state,
+		SecretsVersion: secretsVersion,
+	}
+	return r.Update(context.Background(), a)
+}
+
+// IsUptoDate returns true if a azure auth config is current
+func (r *AzureAuthConfigReconciler) IsUptoDate(a *apiv1.AzureAuthConfig, secretsVersion string) (bool, error) {
+	hash, err := a.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating azureauthconfig hash: %v", err)
+	}
+	if a.Status == nil {
+		return false, nil
+	}
+	if a.Status.Hash != hash || a.Status.SecretsVersion != secretsVersion {
+		return false, nil
+	}
+	return true, nil
+}
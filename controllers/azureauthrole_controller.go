@@ -0,0 +1,202 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// AzureAuthRoleReconciler reconciles a AzureAuthRole object
+type AzureAuthRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=azureauthroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=azureauthroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *AzureAuthRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("azureauthrole", req.NamespacedName)
+
+	role := &apiv1.AzureAuthRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking azureauthrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating azure auth role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating azureauthrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.AzureAuthRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "azure auth role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *AzureAuthRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *AzureAuthRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.AzureAuthRole{}).
+		Complete(r)
+}
+
+func (r *AzureAuthRoleReconciler) delete(a *apiv1.AzureAuthRole) error {
+	r.Log.Info(fmt.Sprintf("deleting azure auth role %s", a.GetName()))
+	if a.Status == nil || a.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/role/%s", a.Status.Path, a.Spec.Name))
+	return err
+}
+
+func (r *AzureAuthRoleReconciler) put(a *apiv1.AzureAuthRole) error {
+	path, err := getAuthMountPath(r.Client, a.GetNamespace(), a.Spec.SysAuthRef, "azure")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"bound_service_principal_ids": a.Spec.BoundServicePrincipalIDs,
+		"bound_group_ids":             a.Spec.BoundGroupIDs,
+		"bound_locations":             a.Spec.BoundLocations,
+		"bound_subscription_ids":      a.Spec.BoundSubscriptionIDs,
+		"bound_resource_groups":       a.Spec.BoundResourceGroups,
+		"bound_scale_sets":            a.Spec.BoundScaleSets,
+		"token_policies":              a.Spec.TokenPolicies,
+		"token_ttl":                   a.Spec.TokenTTL,
+		"token_max_ttl":               a.Spec.TokenMaxTTL,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s", path, a.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := a.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.AzureAuthRoleUpdatedState
+	if !a.IsCreated() {
+		state = apiv1.AzureAuthRoleCreatedState
+	}
+	a.Status = &apiv1.AzureAuthRoleStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), a)
+}
+
+// IsUptoDate returns true if a azure auth role is current
+func (r *AzureAuthRoleReconciler) IsUptoDate(a *apiv1.AzureAuthRole) (bool, error) {
+	hash, err := a.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating azureauthrole hash: %v", err)
+	}
+	if a.Status == nil {
+		return false, nil
+	}
+	if a.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
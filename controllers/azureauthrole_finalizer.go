@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *AzureAuthRoleReconciler) addFinalizer(instance *apiv1.AzureAuthRole) error {
+	instance.AddFinalizer(apiv1.AzureAuthRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *AzureAuthRoleReconciler) handleFinalizer(a *apiv1.AzureAuthRole) error {
+	if !a.HasFinalizer(apiv1.AzureAuthRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(a); err != nil {
+		return err
+	}
+	a.RemoveFinalizer(apiv1.AzureAuthRoleFinalizer)
+	return r.Update(context.Background(), a)
+}
@@ -0,0 +1,255 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// CertAuthRoleReconciler reconciles a CertAuthRole object
+type CertAuthRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=certauthroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=certauthroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *CertAuthRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("certauthrole", req.NamespacedName)
+
+	role := &apiv1.CertAuthRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	certificate, certificateVersion, err := r.getCertificate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get certificate: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting certificate: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(role, certificateVersion)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking certauthrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating cert auth role %v", role.Spec.Name))
+		if err := r.put(role, certificate, certificateVersion); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating certauthrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.CertAuthRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "cert auth role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *CertAuthRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *CertAuthRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.CertAuthRole{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.certificateToRoles("Secret"),
+		}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.certificateToRoles("ConfigMap"),
+		}).
+		Complete(r)
+}
+
+// certificateToRoles enqueues the roles whose certificate is held by an object of the input kind
+func (r *CertAuthRoleReconciler) certificateToRoles(kind string) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		roles := &apiv1.CertAuthRoleList{}
+		if err := r.List(context.Background(), roles, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+			r.Log.Error(err, "failed to list cert auth roles")
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, role := range roles.Items {
+			if role.Spec == nil {
+				continue
+			}
+			if role.Spec.Certificate.Kind == kind && role.Spec.Certificate.Name == o.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: role.GetName(), Namespace: role.GetNamespace()},
+				})
+			}
+		}
+		return requests
+	}
+}
+
+func (r *CertAuthRoleReconciler) getCertificate(c *apiv1.CertAuthRole) (string, string, error) {
+	ref := c.Spec.Certificate
+	if ref.Kind == "ConfigMap" {
+		return getConfigMapValue(r.Client, c.GetNamespace(), ref.Name, ref.Key)
+	}
+	return getSecretValue(r.Client, c.GetNamespace(), &apiv1.SecretKeyReference{Name: ref.Name, Key: ref.Key})
+}
+
+func (r *CertAuthRoleReconciler) delete(c *apiv1.CertAuthRole) error {
+	r.Log.Info(fmt.Sprintf("deleting cert auth role %s", c.GetName()))
+	if c.Status == nil || c.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/certs/%s", c.Status.Path, c.Spec.Name))
+	return err
+}
+
+func (r *CertAuthRoleReconciler) put(c *apiv1.CertAuthRole, certificate, certificateVersion string) error {
+	path, err := getAuthMountPath(r.Client, c.GetNamespace(), c.Spec.SysAuthRef, "cert")
+	if err != nil {
+		return err
+	}
+	notAfter, err := certificatesNotAfter(certificate)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"display_name":         c.Spec.DisplayName,
+		"certificate":          certificate,
+		"allowed_common_names": c.Spec.AllowedCommonNames,
+		"allowed_dns_sans":     c.Spec.AllowedDNSSANs,
+		"token_policies":       c.Spec.TokenPolicies,
+		"token_ttl":            c.Spec.TokenTTL,
+		"token_max_ttl":        c.Spec.TokenMaxTTL,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/certs/%s", path, c.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := c.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.CertAuthRoleUpdatedState
+	if !c.IsCreated() {
+		state = apiv1.CertAuthRoleCreatedState
+	}
+	expiry := metav1.NewTime(notAfter)
+	c.Status = &apiv1.CertAuthRoleStatus{
+		Hash:               hash,
+		State:              state,
+		Path:               path,
+		CertificateVersion: certificateVersion,
+		NotAfter:           &expiry,
+	}
+	return r.Update(context.Background(), c)
+}
+
+// IsUptoDate returns true if a cert auth role is current
+func (r *CertAuthRoleReconciler) IsUptoDate(c *apiv1.CertAuthRole, certificateVersion string) (bool, error) {
+	hash, err := c.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating certauthrole hash: %v", err)
+	}
+	if c.Status == nil {
+		return false, nil
+	}
+	if c.Status.Hash != hash || c.Status.CertificateVersion != certificateVersion {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *CertAuthRoleReconciler) addFinalizer(instance *apiv1.CertAuthRole) error {
+	instance.AddFinalizer(apiv1.CertAuthRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *CertAuthRoleReconciler) handleFinalizer(c *apiv1.CertAuthRole) error {
+	if !c.HasFinalizer(apiv1.CertAuthRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(c); err != nil {
+		return err
+	}
+	c.RemoveFinalizer(apiv1.CertAuthRoleFinalizer)
+	return r.Update(context.Background(), c)
+}
@@ -0,0 +1,276 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestCloudAuthRoleReconcile(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Name: "dev", Namespace: apiv1.WatchNamespace}
+	tests := []struct {
+		name       string
+		sysauth    *apiv1.SysAuth
+		role       runtime.Object
+		reconciler func(c client.Client) reconcile.Reconciler
+		path       string
+		expected   map[string]interface{}
+		absent     []string
+	}{
+		{
+			name:    "aws",
+			sysauth: newCreatedSysAuth("aws", "aws"),
+			role: &apiv1.AWSAuthRole{
+				ObjectMeta: objectMeta,
+				Spec: &apiv1.AWSAuthRoleSpec{
+					SysAuthRef:            "aws",
+					Name:                  "dev",
+					AuthType:              "iam",
+					BoundIAMPrincipalARNs: []string{"arn:aws:iam::123456789012:role/dev"},
+					TokenPolicies:         []string{"dev"},
+				},
+			},
+			reconciler: func(c client.Client) reconcile.Reconciler {
+				return &AWSAuthRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			},
+			path: "auth/aws/role/dev",
+			expected: map[string]interface{}{
+				"auth_type":               "iam",
+				"bound_iam_principal_arn": []interface{}{"arn:aws:iam::123456789012:role/dev"},
+				"token_policies":          []interface{}{"dev"},
+			},
+		},
+		{
+			name:    "aws without auth_type",
+			sysauth: newCreatedSysAuth("aws", "aws"),
+			role: &apiv1.AWSAuthRole{
+				ObjectMeta: objectMeta,
+				Spec: &apiv1.AWSAuthRoleSpec{
+					SysAuthRef:            "aws",
+					Name:                  "dev",
+					BoundIAMPrincipalARNs: []string{"arn:aws:iam::123456789012:role/dev"},
+				},
+			},
+			reconciler: func(c client.Client) reconcile.Reconciler {
+				return &AWSAuthRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			},
+			path: "auth/aws/role/dev",
+			expected: map[string]interface{}{
+				"bound_iam_principal_arn": []interface{}{"arn:aws:iam::123456789012:role/dev"},
+			},
+			absent: []string{"auth_type"},
+		},
+		{
+			name:    "gcp",
+			sysauth: newCreatedSysAuth("gcp", "gcp"),
+			role: &apiv1.GCPAuthRole{
+				ObjectMeta: objectMeta,
+				Spec: &apiv1.GCPAuthRoleSpec{
+					SysAuthRef:           "gcp",
+					Name:                 "dev",
+					Type:                 "iam",
+					BoundServiceAccounts: []string{"dev@project.iam.gserviceaccount.com"},
+					BoundProjects:        []string{"project"},
+				},
+			},
+			reconciler: func(c client.Client) reconcile.Reconciler {
+				return &GCPAuthRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			},
+			path: "auth/gcp/role/dev",
+			expected: map[string]interface{}{
+				"type":                   "iam",
+				"bound_service_accounts": []interface{}{"dev@project.iam.gserviceaccount.com"},
+				"bound_projects":         []interface{}{"project"},
+			},
+		},
+		{
+			name:    "azure",
+			sysauth: newCreatedSysAuth("azure", "azure"),
+			role: &apiv1.AzureAuthRole{
+				ObjectMeta: objectMeta,
+				Spec: &apiv1.AzureAuthRoleSpec{
+					SysAuthRef:           "azure",
+					Name:                 "dev",
+					BoundSubscriptionIDs: []string{"subscription"},
+					BoundResourceGroups:  []string{"group"},
+				},
+			},
+			reconciler: func(c client.Client) reconcile.Reconciler {
+				return &AzureAuthRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			},
+			path: "auth/azure/role/dev",
+			expected: map[string]interface{}{
+				"bound_subscription_ids": []interface{}{"subscription"},
+				"bound_resource_groups":  []interface{}{"group"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := newFakeVault()
+			defer vault.Close()
+			c := newFakeClient(t, vault, tt.sysauth, tt.role)
+			r := tt.reconciler(c)
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "dev", Namespace: apiv1.WatchNamespace}}
+
+			if _, err := r.Reconcile(req); err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			data, ok := vault.get(tt.path)
+			if !ok {
+				t.Fatalf("expected %s to be written", tt.path)
+			}
+			for key, value := range tt.expected {
+				if !reflect.DeepEqual(data[key], value) {
+					t.Errorf("expected %s to be %v, got %v", key, value, data[key])
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := data[key]; ok {
+					t.Errorf("expected %s not to be sent, got %v", key, data[key])
+				}
+			}
+
+			obj := tt.role.DeepCopyObject()
+			if err := c.Get(context.TODO(), req.NamespacedName, obj); err != nil {
+				t.Fatal(err)
+			}
+			accessor, err := apimeta.Accessor(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(accessor.GetFinalizers()) != 1 {
+				t.Fatalf("expected a finalizer, got %v", accessor.GetFinalizers())
+			}
+
+			now := metav1.Now()
+			accessor.SetDeletionTimestamp(&now)
+			if err := c.Update(context.TODO(), obj); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(req); err != nil {
+				t.Fatalf("unexpected reconcile error on delete: %v", err)
+			}
+			if !vault.deleted(tt.path) {
+				t.Errorf("expected %s to be deleted", tt.path)
+			}
+		})
+	}
+}
+
+func TestCloudAuthRoleWrongMountType(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	role := &apiv1.AWSAuthRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: apiv1.WatchNamespace},
+		Spec:       &apiv1.AWSAuthRoleSpec{SysAuthRef: "gcp", Name: "dev"},
+	}
+	c := newFakeClient(t, vault, newCreatedSysAuth("gcp", "gcp"), role)
+	r := &AWSAuthRoleReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "dev", Namespace: apiv1.WatchNamespace}}
+	if _, err := r.Reconcile(req); err == nil {
+		t.Fatal("expected an error for a sysauth of another type")
+	}
+	if _, ok := vault.get("auth/gcp/role/dev"); ok {
+		t.Error("expected nothing to be written")
+	}
+}
+
+func TestAWSAuthConfigReconcile(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: apiv1.WatchNamespace},
+		Data: map[string][]byte{
+			"access_key": []byte("AKIA"),
+			"secret_key": []byte("secret"),
+		},
+	}
+	authConfig := &apiv1.AWSAuthConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.AWSAuthConfigSpec{
+			SysAuthRef: "aws",
+			AccessKey:  &apiv1.SecretKeyReference{Name: "aws", Key: "access_key"},
+			SecretKey:  &apiv1.SecretKeyReference{Name: "aws", Key: "secret_key"},
+			STSRegion:  "eu-west-1",
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSysAuth("aws", "aws"), secret, authConfig)
+	r := &AWSAuthConfigReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "aws", Namespace: apiv1.WatchNamespace}}
+
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	data, ok := vault.get("auth/aws/config/client")
+	if !ok {
+		t.Fatal("expected auth/aws/config/client to be written")
+	}
+	if data["access_key"] != "AKIA" || data["secret_key"] != "secret" || data["sts_region"] != "eu-west-1" {
+		t.Errorf("unexpected config written: %v", data)
+	}
+
+	// rotating the credentials rewrites the config
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "aws", Namespace: apiv1.WatchNamespace}, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret.Data["secret_key"] = []byte("rotated")
+	if err := c.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	data, _ = vault.get("auth/aws/config/client")
+	if data["secret_key"] != "rotated" {
+		t.Errorf("expected the rotated secret key to be written, got %v", data["secret_key"])
+	}
+
+	if err := c.Get(context.TODO(), req.NamespacedName, authConfig); err != nil {
+		t.Fatal(err)
+	}
+	if !authConfig.HasFinalizer(apiv1.AWSAuthConfigFinalizer) {
+		t.Fatalf("expected a finalizer, got %v", authConfig.GetFinalizers())
+	}
+	now := metav1.Now()
+	authConfig.SetDeletionTimestamp(&now)
+	if err := c.Update(context.TODO(), authConfig); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error on delete: %v", err)
+	}
+	if !vault.deleted("auth/aws/config/client") {
+		t.Error("expected auth/aws/config/client to be deleted")
+	}
+}
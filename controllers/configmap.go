@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// publishConfigMap creates or updates a ConfigMap owned by the input object with the input data,
+// an existing ConfigMap owned by anything else is left untouched
+func publishConfigMap(c client.Client, scheme *runtime.Scheme, owner metav1.Object, name string, data map[string]string) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: owner.GetNamespace(),
+		},
+	}
+	return writeOwned(c, scheme, owner, configMap, func() {
+		configMap.Data = data
+	})
+}
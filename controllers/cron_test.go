@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	after := time.Date(2020, time.January, 31, 23, 30, 0, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2020, time.January, 31, 23, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2020, time.February, 1, 2, 0, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 4 * * 1", time.Date(2020, time.February, 3, 4, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", c.expr, err)
+		}
+		if got := schedule.Next(after); !got.Equal(c.want) {
+			t.Errorf("Next(%q) = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// DatabaseConnectionReconciler reconciles a DatabaseConnection object
+type DatabaseConnectionReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databaseconnections,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databaseconnections/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *DatabaseConnectionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("databaseconnection", req.NamespacedName)
+
+	connection := &apiv1.DatabaseConnection{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, connection)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if connection.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(connection)
+		if err != nil {
+			r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(connection, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	salt, err := secretsSalt(connection)
+	if err != nil {
+		r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to generate secrets salt: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when generating secrets salt: %v", err)
+	}
+	secrets, secretsHash, err := r.getSecrets(connection, salt)
+	if err != nil {
+		r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get credentials: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting credentials: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(connection, secretsHash)
+	if err != nil {
+		r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking databaseconnection IsUptoDate: %v", err)
+	}
+
+	if !connection.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating database connection %v", connection.Spec.Name))
+		if err := r.put(connection, secrets, salt, secretsHash); err != nil {
+			if !connection.IsCreated() {
+				r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating databaseconnection: %v", err)
+		}
+
+		if !connection.HasFinalizer(apiv1.DatabaseConnectionFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(connection); err != nil {
+				r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(connection, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(connection, corev1.EventTypeNormal, "updated", "database connection is updated")
+	}
+
+	// the password of the Secret is rotated once, a new password in the Secret is rotated again
+	if connection.Spec.RotateRoot && (connection.Status.LastRootRotation == nil || connection.Status.RootRotatedHash != secretsHash) {
+		r.Log.Info(fmt.Sprintf("rotating root credentials of database connection %v", connection.Spec.Name))
+		if err := r.rotateRoot(connection, secretsHash); err != nil {
+			r.Recorder.Event(connection, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to rotate root credentials: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when rotating databaseconnection root: %v", err)
+		}
+		r.Recorder.Event(connection, corev1.EventTypeNormal, "rotated", "root credentials are rotated")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *DatabaseConnectionReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *DatabaseConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.DatabaseConnection{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretToConnections),
+		}).
+		Complete(r)
+}
+
+// secretToConnections enqueues the connections whose root credentials are held by a Secret
+func (r *DatabaseConnectionReconciler) secretToConnections(o handler.MapObject) []reconcile.Request {
+	connections := &apiv1.DatabaseConnectionList{}
+	if err := r.List(context.Background(), connections, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list database connections")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, connection := range connections.Items {
+		if connection.Spec == nil {
+			continue
+		}
+		for _, ref := range []*apiv1.SecretKeyReference{connection.Spec.Username, connection.Spec.Password} {
+			if ref != nil && ref.Name == o.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: connection.GetName(), Namespace: connection.GetNamespace()},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// getSecrets returns the credentials referenced by the spec keyed by vault parameter, along with an HMAC
+// of their values keyed by salt so that the status tells changes apart without exposing the credentials
+func (r *DatabaseConnectionReconciler) getSecrets(d *apiv1.DatabaseConnection, salt string) (map[string]string, string, error) {
+	values := map[string]string{}
+	if d.Spec.Username != nil {
+		value, _, err := getSecretValue(r.Client, d.GetNamespace(), d.Spec.Username)
+		if err != nil {
+			return nil, "", err
+		}
+		values["username"] = value
+	}
+	if d.Spec.Password != nil {
+		value, _, err := getSecretValue(r.Client, d.GetNamespace(), d.Spec.Password)
+		if err != nil {
+			return nil, "", err
+		}
+		values["password"] = value
+	}
+	// json sorts the map keys, the encoding is stable
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return nil, "", err
+	}
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write(encoded)
+	return values, hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// secretsSalt returns the salt of the status, or a new random one when the status has none
+func secretsSalt(d *apiv1.DatabaseConnection) (string, error) {
+	if d.Status != nil && d.Status.SecretsSalt != "" {
+		return d.Status.SecretsSalt, nil
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+func (r *DatabaseConnectionReconciler) delete(d *apiv1.DatabaseConnection) error {
+	r.Log.Info(fmt.Sprintf("deleting database connection %s", d.GetName()))
+	if d.Status == nil || d.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("%s/config/%s", d.Status.Path, d.Spec.Name))
+	return err
+}
+
+func (r *DatabaseConnectionReconciler) put(d *apiv1.DatabaseConnection, secrets map[string]string, salt, secretsHash string) error {
+	path, err := resolveMountPath(r.Client, d.GetNamespace(), d.Spec.SecretsEngineRef, d.Spec.Mount, "database")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"plugin_name":    d.Spec.PluginName,
+		"connection_url": d.Spec.ConnectionURL,
+		"allowed_roles":  d.Spec.AllowedRoles,
+	}
+	if d.Spec.PasswordPolicy != "" {
+		data["password_policy"] = d.Spec.PasswordPolicy
+	}
+	if d.Spec.VerifyConnection != nil {
+		data["verify_connection"] = *d.Spec.VerifyConnection
+	}
+	var rotated *metav1.Time
+	var rotatedHash string
+	if d.IsCreated() {
+		rotated = d.Status.LastRootRotation
+		rotatedHash = d.Status.RootRotatedHash
+	}
+	for key, value := range secrets {
+		// vault keeps the rotated password when none is sent
+		if key == "password" && rotated != nil && rotatedHash == secretsHash {
+			continue
+		}
+		data[key] = value
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/config/%s", path, d.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := d.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.DatabaseConnectionUpdatedState
+	if !d.IsCreated() {
+		state = apiv1.DatabaseConnectionCreatedState
+	}
+	d.Status = &apiv1.DatabaseConnectionStatus{
+		Hash:             hash,
+		State:            state,
+		Path:             path,
+		SecretsHash:      secretsHash,
+		SecretsSalt:      salt,
+		LastRootRotation: rotated,
+		RootRotatedHash:  rotatedHash,
+	}
+	return r.Update(context.Background(), d)
+}
+
+func (r *DatabaseConnectionReconciler) rotateRoot(d *apiv1.DatabaseConnection, secretsHash string) error {
+	_, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/rotate-root/%s", d.Status.Path, d.Spec.Name), nil)
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	d.Status.LastRootRotation = &now
+	d.Status.RootRotatedHash = secretsHash
+	return r.Update(context.Background(), d)
+}
+
+// IsUptoDate returns true if a database connection is current
+func (r *DatabaseConnectionReconciler) IsUptoDate(d *apiv1.DatabaseConnection, secretsHash string) (bool, error) {
+	hash, err := d.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating databaseconnection hash: %v", err)
+	}
+	if d.Status == nil {
+		return false, nil
+	}
+	if d.Status.Hash != hash || d.Status.SecretsHash != secretsHash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *DatabaseConnectionReconciler) addFinalizer(instance *apiv1.DatabaseConnection) error {
+	instance.AddFinalizer(apiv1.DatabaseConnectionFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *DatabaseConnectionReconciler) handleFinalizer(d *apiv1.DatabaseConnection) error {
+	if !d.HasFinalizer(apiv1.DatabaseConnectionFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(d); err != nil {
+		return err
+	}
+	d.RemoveFinalizer(apiv1.DatabaseConnectionFinalizer)
+	return r.Update(context.Background(), d)
+}
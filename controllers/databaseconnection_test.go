@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestDatabaseConnectionRotateRoot(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: apiv1.WatchNamespace},
+		Data: map[string][]byte{
+			"username": []byte("vault"),
+			"password": []byte("initial"),
+		},
+	}
+	connection := &apiv1.DatabaseConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.DatabaseConnectionSpec{
+			SecretsEngineRef: "database",
+			Name:             "db",
+			PluginName:       "postgresql-database-plugin",
+			ConnectionURL:    "postgresql://{{username}}:{{password}}@db:5432/app",
+			Username:         &apiv1.SecretKeyReference{Name: "db", Key: "username"},
+			Password:         &apiv1.SecretKeyReference{Name: "db", Key: "password"},
+			RotateRoot:       true,
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSecretsEngine("database", "database"), secret, connection)
+	r := &DatabaseConnectionReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(20)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "db", Namespace: apiv1.WatchNamespace}}
+
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	data, ok := vault.get("database/config/db")
+	if !ok || data["password"] != "initial" {
+		t.Fatalf("expected the connection to be written with the initial password, got %v", data)
+	}
+	if _, ok := vault.get("database/rotate-root/db"); !ok {
+		t.Fatal("expected the root credentials to be rotated")
+	}
+	if err := c.Get(context.TODO(), req.NamespacedName, connection); err != nil {
+		t.Fatal(err)
+	}
+	salt := connection.Status.SecretsSalt
+	if salt == "" || connection.Status.SecretsHash == "" {
+		t.Fatalf("expected a salted secrets hash in status, got %+v", connection.Status)
+	}
+
+	// a metadata change of the Secret must not push the rotated password again
+	vault.set("database/config/db", map[string]interface{}{})
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "db", Namespace: apiv1.WatchNamespace}, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret.Labels = map[string]string{"team": "data"}
+	if err := c.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if data, _ := vault.get("database/config/db"); data["password"] != nil {
+		t.Errorf("expected the stale password not to be written, got %v", data["password"])
+	}
+
+	// a new password is written and rotated again
+	secret.Data["password"] = []byte("reset")
+	if err := c.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if data, _ := vault.get("database/config/db"); data["password"] != "reset" {
+		t.Errorf("expected the new password to be written, got %v", data["password"])
+	}
+	if err := c.Get(context.TODO(), req.NamespacedName, connection); err != nil {
+		t.Fatal(err)
+	}
+	if connection.Status.SecretsSalt != salt {
+		t.Error("expected the secrets salt to be kept")
+	}
+}
@@ -0,0 +1,200 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// DatabaseRoleReconciler reconciles a DatabaseRole object
+type DatabaseRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databaseroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databaseroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databaseconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *DatabaseRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("databaserole", req.NamespacedName)
+
+	role := &apiv1.DatabaseRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking databaserole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating database role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating databaserole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.DatabaseRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "database role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *DatabaseRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *DatabaseRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.DatabaseRole{}).
+		Complete(r)
+}
+
+func (r *DatabaseRoleReconciler) delete(d *apiv1.DatabaseRole) error {
+	r.Log.Info(fmt.Sprintf("deleting database role %s", d.GetName()))
+	if d.Status == nil || d.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("%s/roles/%s", d.Status.Path, d.Spec.Name))
+	return err
+}
+
+func (r *DatabaseRoleReconciler) put(d *apiv1.DatabaseRole) error {
+	path, dbName, err := getDatabaseConnection(r.Client, d.GetNamespace(), d.Spec.DatabaseConnectionRef)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"db_name":               dbName,
+		"creation_statements":   d.Spec.CreationStatements,
+		"revocation_statements": d.Spec.RevocationStatements,
+		"rollback_statements":   d.Spec.RollbackStatements,
+		"renew_statements":      d.Spec.RenewStatements,
+		"default_ttl":           d.Spec.DefaultTTL,
+		"max_ttl":               d.Spec.MaxTTL,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/roles/%s", path, d.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := d.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.DatabaseRoleUpdatedState
+	if !d.IsCreated() {
+		state = apiv1.DatabaseRoleCreatedState
+	}
+	d.Status = &apiv1.DatabaseRoleStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), d)
+}
+
+// IsUptoDate returns true if a database role is current
+func (r *DatabaseRoleReconciler) IsUptoDate(d *apiv1.DatabaseRole) (bool, error) {
+	hash, err := d.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating databaserole hash: %v", err)
+	}
+	if d.Status == nil {
+		return false, nil
+	}
+	if d.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *DatabaseRoleReconciler) addFinalizer(instance *apiv1.DatabaseRole) error {
+	instance.AddFinalizer(apiv1.DatabaseRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *DatabaseRoleReconciler) handleFinalizer(d *apiv1.DatabaseRole) error {
+	if !d.HasFinalizer(apiv1.DatabaseRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(d); err != nil {
+		return err
+	}
+	d.RemoveFinalizer(apiv1.DatabaseRoleFinalizer)
+	return r.Update(context.Background(), d)
+}
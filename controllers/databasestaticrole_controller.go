@@ -0,0 +1,305 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// staticCredsSyncDelay is the delay after the rotation before the credentials are read again
+const staticCredsSyncDelay = 5 * time.Second
+
+// DatabaseStaticRoleReconciler reconciles a DatabaseStaticRole object
+type DatabaseStaticRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databasestaticroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databasestaticroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=databaseconnections,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *DatabaseStaticRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("databasestaticrole", req.NamespacedName)
+
+	role := &apiv1.DatabaseStaticRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking databasestaticrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating database static role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating databasestaticrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.DatabaseStaticRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "database static role is updated")
+	}
+
+	if role.Spec.SecretName == "" {
+		return ctrl.Result{}, nil
+	}
+	ttl, err := r.sync(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to sync credentials: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when syncing databasestaticrole credentials: %v", err)
+	}
+	// vault rotates the password once the ttl expires, the Secret is synced right after
+	return ctrl.Result{RequeueAfter: ttl + staticCredsSyncDelay}, nil
+}
+
+func (r *DatabaseStaticRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *DatabaseStaticRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.DatabaseStaticRole{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+func (r *DatabaseStaticRoleReconciler) delete(d *apiv1.DatabaseStaticRole) error {
+	r.Log.Info(fmt.Sprintf("deleting database static role %s", d.GetName()))
+	if d.Status == nil || d.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("%s/static-roles/%s", d.Status.Path, d.Spec.Name))
+	return err
+}
+
+func (r *DatabaseStaticRoleReconciler) put(d *apiv1.DatabaseStaticRole) error {
+	path, dbName, err := getDatabaseConnection(r.Client, d.GetNamespace(), d.Spec.DatabaseConnectionRef)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"db_name":         dbName,
+		"username":        d.Spec.Username,
+		"rotation_period": d.Spec.RotationPeriod,
+	}
+	if len(d.Spec.RotationStatements) > 0 {
+		data["rotation_statements"] = d.Spec.RotationStatements
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/static-roles/%s", path, d.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := d.GetHash()
+	if err != nil {
+		return err
+	}
+	status := &apiv1.DatabaseStaticRoleStatus{
+		Hash:  hash,
+		State: apiv1.DatabaseStaticRoleCreatedState,
+		Path:  path,
+	}
+	if d.IsCreated() {
+		status.State = apiv1.DatabaseStaticRoleUpdatedState
+		status.SecretName = d.Status.SecretName
+		status.LastVaultRotation = d.Status.LastVaultRotation
+		status.LastSynced = d.Status.LastSynced
+		status.NextRotation = d.Status.NextRotation
+	}
+	d.Status = status
+	return r.Update(context.Background(), d)
+}
+
+// sync writes the static credentials to the Secret when vault rotated them, and returns the time until the next rotation
+func (r *DatabaseStaticRoleReconciler) sync(d *apiv1.DatabaseStaticRole) (time.Duration, error) {
+	secret, err := r.APIClient.Logical().Read(fmt.Sprintf("%s/static-creds/%s", d.Status.Path, d.Spec.Name))
+	if err != nil {
+		return 0, err
+	}
+	if secret == nil {
+		return 0, fmt.Errorf("static credentials of %s not found", d.Spec.Name)
+	}
+	seconds, err := toInt64(secret.Data["ttl"])
+	if err != nil {
+		return 0, err
+	}
+	ttl := time.Duration(seconds) * time.Second
+	lastRotation, _ := secret.Data["last_vault_rotation"].(string)
+
+	exists, err := r.secretExists(d)
+	if err != nil {
+		return 0, err
+	}
+	if exists && d.Status.SecretName == d.Spec.SecretName && d.Status.LastVaultRotation == lastRotation {
+		return ttl, nil
+	}
+
+	username, _ := secret.Data["username"].(string)
+	password, _ := secret.Data["password"].(string)
+	if err := r.writeSecret(d, map[string]string{"username": username, "password": password}); err != nil {
+		return 0, err
+	}
+	rotated := d.Status.LastVaultRotation != "" && d.Status.LastVaultRotation != lastRotation
+	now := metav1.Now()
+	next := metav1.NewTime(now.Add(ttl))
+	d.Status.SecretName = d.Spec.SecretName
+	d.Status.LastVaultRotation = lastRotation
+	d.Status.LastSynced = &now
+	d.Status.NextRotation = &next
+	if err := r.Update(context.Background(), d); err != nil {
+		return 0, err
+	}
+	if rotated {
+		r.Recorder.Event(d, corev1.EventTypeNormal, "rotated", fmt.Sprintf("rotated credentials are written to secret %s", d.Spec.SecretName))
+	} else {
+		r.Recorder.Event(d, corev1.EventTypeNormal, "updated", fmt.Sprintf("credentials are written to secret %s", d.Spec.SecretName))
+	}
+	return ttl, nil
+}
+
+func (r *DatabaseStaticRoleReconciler) secretExists(d *apiv1.DatabaseStaticRole) (bool, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: d.Spec.SecretName, Namespace: d.GetNamespace()}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *DatabaseStaticRoleReconciler) writeSecret(d *apiv1.DatabaseStaticRole, data map[string]string) error {
+	if d.Status.SecretName != "" && d.Status.SecretName != d.Spec.SecretName {
+		// the Secret was renamed, the previous one is removed
+		previous := &corev1.Secret{}
+		err := r.Get(context.Background(), types.NamespacedName{Name: d.Status.SecretName, Namespace: d.GetNamespace()}, previous)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(previous, d) {
+			if err := r.Delete(context.Background(), previous); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      d.Spec.SecretName,
+			Namespace: d.GetNamespace(),
+		},
+	}
+	return writeOwned(r.Client, r.Scheme, d, secret, func() {
+		secret.Data = map[string][]byte{}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+	})
+}
+
+// IsUptoDate returns true if a database static role is current
+func (r *DatabaseStaticRoleReconciler) IsUptoDate(d *apiv1.DatabaseStaticRole) (bool, error) {
+	hash, err := d.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating databasestaticrole hash: %v", err)
+	}
+	if d.Status == nil {
+		return false, nil
+	}
+	if d.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
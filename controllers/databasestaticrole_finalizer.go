@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *DatabaseStaticRoleReconciler) addFinalizer(instance *apiv1.DatabaseStaticRole) error {
+	instance.AddFinalizer(apiv1.DatabaseStaticRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *DatabaseStaticRoleReconciler) handleFinalizer(d *apiv1.DatabaseStaticRole) error {
+	if !d.HasFinalizer(apiv1.DatabaseStaticRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(d); err != nil {
+		return err
+	}
+	d.RemoveFinalizer(apiv1.DatabaseStaticRoleFinalizer)
+	return r.Update(context.Background(), d)
+}
@@ -0,0 +1,423 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// rotatedAtAnnotation is set on the pod template of rollout targets to restart them with rotated credentials
+const rotatedAtAnnotation = "vault.gobins.github.io/rotated-at"
+
+// defaultGracePeriod is used when a dynamic secret has no grace_period
+const defaultGracePeriod = 5 * time.Minute
+
+// DynamicSecretReconciler reconciles a DynamicSecret object
+type DynamicSecretReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=dynamicsecrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=dynamicsecrets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *DynamicSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("dynamicsecret", req.NamespacedName)
+
+	dynamicSecret := &apiv1.DynamicSecret{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, dynamicSecret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if dynamicSecret.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(dynamicSecret)
+		if err != nil {
+			r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	if err := dynamicSecret.Spec.Validate(); err != nil {
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.revokeDue(dynamicSecret); err != nil {
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to revoke previous lease: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when revoking previous lease: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(dynamicSecret)
+	if err != nil {
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking dynamicsecret IsUptoDate: %v", err)
+	}
+
+	rotate := !dynamicSecret.IsCreated() || !isUptoDate
+	if !rotate && r.renewDue(dynamicSecret) {
+		renewed, err := r.renew(dynamicSecret)
+		if err != nil {
+			r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to renew lease: %s", err))
+		}
+		rotate = !renewed
+	}
+
+	if rotate {
+		r.Log.Info(fmt.Sprintf("fetching credentials from %v", dynamicSecret.Spec.Path))
+		if err := r.rotate(dynamicSecret); err != nil {
+			r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to fetch credentials: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when fetching dynamicsecret credentials: %v", err)
+		}
+
+		if !dynamicSecret.HasFinalizer(apiv1.DynamicSecretFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(dynamicSecret); err != nil {
+				r.Recorder.Event(dynamicSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(dynamicSecret, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(dynamicSecret, corev1.EventTypeNormal, "rotated", fmt.Sprintf("credentials are written to secret %s", dynamicSecret.GetSecretName()))
+	}
+
+	return ctrl.Result{RequeueAfter: r.nextReconcile(dynamicSecret)}, nil
+}
+
+func (r *DynamicSecretReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *DynamicSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.DynamicSecret{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+func (r *DynamicSecretReconciler) delete(d *apiv1.DynamicSecret) error {
+	r.Log.Info(fmt.Sprintf("revoking leases of dynamic secret %s", d.GetName()))
+	if d.Status == nil {
+		return nil
+	}
+	for _, revocation := range d.Status.PendingRevocations {
+		if err := r.APIClient.Sys().Revoke(revocation.LeaseID); err != nil {
+			return err
+		}
+	}
+	if d.Status.LeaseID == "" {
+		return nil
+	}
+	// the Secret is garbage collected along with the object
+	return r.APIClient.Sys().Revoke(d.Status.LeaseID)
+}
+
+// renewThreshold returns how long before the lease expiry it is renewed or rotated, rotate_before is
+// capped at half of the lease duration so that credentials are not rotated over and over
+func renewThreshold(d *apiv1.DynamicSecret) time.Duration {
+	lease := time.Duration(d.Status.LeaseDuration) * time.Second
+	if d.Spec.RotateBefore != "" {
+		before, _ := time.ParseDuration(d.Spec.RotateBefore)
+		if before > lease/2 {
+			return lease / 2
+		}
+		return before
+	}
+	return lease / 3
+}
+
+// renewDue returns true if the lease is close enough to its expiry to be renewed
+func (r *DynamicSecretReconciler) renewDue(d *apiv1.DynamicSecret) bool {
+	if d.Status.LeaseID == "" || d.Status.LeaseExpiry == nil {
+		return false
+	}
+	return !time.Now().Before(d.Status.LeaseExpiry.Add(-renewThreshold(d)))
+}
+
+// renew extends the lease and returns false when fresh credentials are needed because it neared its max TTL
+func (r *DynamicSecretReconciler) renew(d *apiv1.DynamicSecret) (bool, error) {
+	if !d.Status.Renewable {
+		return false, nil
+	}
+	secret, err := r.APIClient.Sys().Renew(d.Status.LeaseID, int(d.Status.LeaseDuration))
+	if err != nil {
+		return false, err
+	}
+	duration := time.Duration(secret.LeaseDuration) * time.Second
+	if duration <= renewThreshold(d) {
+		return false, nil
+	}
+	expiry := metav1.NewTime(time.Now().Add(duration))
+	d.Status.LeaseExpiry = &expiry
+	d.Status.State = apiv1.DynamicSecretRenewedState
+	if err := r.Update(context.Background(), d); err != nil {
+		return false, err
+	}
+	r.Recorder.Event(d, corev1.EventTypeNormal, "renewed", fmt.Sprintf("lease is renewed until %s", expiry.Format(time.RFC3339)))
+	return true, nil
+}
+
+// rotate fetches fresh credentials, writes them to the Secret and schedules the revocation of the previous lease
+func (r *DynamicSecretReconciler) rotate(d *apiv1.DynamicSecret) error {
+	var secret *vaultapi.Secret
+	var err error
+	if len(d.Spec.Parameters) > 0 {
+		data := map[string]interface{}{}
+		for key, value := range d.Spec.Parameters {
+			data[key] = value
+		}
+		secret, err = r.APIClient.Logical().Write(d.Spec.Path, data)
+	} else {
+		secret, err = r.APIClient.Logical().Read(d.Spec.Path)
+	}
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return fmt.Errorf("no credentials returned by %s", d.Spec.Path)
+	}
+	data, err := renderSecretData(d.Spec.Keys, secret.Data)
+	if err != nil {
+		return err
+	}
+	if err := r.writeSecret(d, data); err != nil {
+		// the credentials are never delivered, their lease is not kept
+		if secret.LeaseID != "" {
+			if revokeErr := r.APIClient.Sys().Revoke(secret.LeaseID); revokeErr != nil {
+				r.Log.Error(revokeErr, fmt.Sprintf("failed to revoke undelivered lease %s", secret.LeaseID))
+			}
+		}
+		return err
+	}
+
+	hash, err := d.GetHash()
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	status := &apiv1.DynamicSecretStatus{
+		Hash:          hash,
+		State:         apiv1.DynamicSecretRotatedState,
+		SecretName:    d.GetSecretName(),
+		LeaseID:       secret.LeaseID,
+		LeaseDuration: int64(secret.LeaseDuration),
+		Renewable:     secret.Renewable,
+		LastRotated:   &now,
+	}
+	if secret.LeaseDuration > 0 {
+		expiry := metav1.NewTime(now.Add(time.Duration(secret.LeaseDuration) * time.Second))
+		status.LeaseExpiry = &expiry
+	}
+	if !d.IsCreated() {
+		status.State = apiv1.DynamicSecretCreatedState
+	} else {
+		status.PendingRevocations = d.Status.PendingRevocations
+		if d.Status.LeaseID != "" {
+			gracePeriod := defaultGracePeriod
+			if d.Spec.GracePeriod != "" {
+				gracePeriod, _ = time.ParseDuration(d.Spec.GracePeriod)
+			}
+			status.PendingRevocations = append(status.PendingRevocations, apiv1.LeaseRevocation{
+				LeaseID:  d.Status.LeaseID,
+				RevokeAt: metav1.NewTime(now.Add(gracePeriod)),
+			})
+		}
+		r.rollout(d, now)
+	}
+	d.Status = status
+	return r.Update(context.Background(), d)
+}
+
+func (r *DynamicSecretReconciler) writeSecret(d *apiv1.DynamicSecret, data map[string]string) error {
+	if d.Status != nil && d.Status.SecretName != "" && d.Status.SecretName != d.GetSecretName() {
+		// the Secret was renamed, the previous one no longer holds valid credentials
+		previous := &corev1.Secret{}
+		err := r.Get(context.Background(), types.NamespacedName{Name: d.Status.SecretName, Namespace: d.GetNamespace()}, previous)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(previous, d) {
+			if err := r.Delete(context.Background(), previous); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      d.GetSecretName(),
+			Namespace: d.GetNamespace(),
+		},
+	}
+	return writeOwned(r.Client, r.Scheme, d, secret, func() {
+		secret.Data = map[string][]byte{}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+	})
+}
+
+// rollout restarts the rollout targets by annotating their pod template, failures only emit events
+func (r *DynamicSecretReconciler) rollout(d *apiv1.DynamicSecret, rotatedAt metav1.Time) {
+	for _, target := range d.Spec.RolloutTargets {
+		key := types.NamespacedName{Name: target.Name, Namespace: d.GetNamespace()}
+		var obj runtime.Object
+		var template *corev1.PodTemplateSpec
+		switch target.Kind {
+		case "StatefulSet":
+			statefulSet := &appsv1.StatefulSet{}
+			obj, template = statefulSet, &statefulSet.Spec.Template
+		default:
+			deployment := &appsv1.Deployment{}
+			obj, template = deployment, &deployment.Spec.Template
+		}
+		if err := r.Get(context.Background(), key, obj); err != nil {
+			r.Recorder.Event(d, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get %s %s for rollout: %s", target.Kind, target.Name, err))
+			continue
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject())
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[rotatedAtAnnotation] = rotatedAt.UTC().Format(time.RFC3339)
+		if err := r.Patch(context.Background(), obj, patch); err != nil {
+			r.Recorder.Event(d, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to roll out %s %s: %s", target.Kind, target.Name, err))
+		}
+	}
+}
+
+// revokeDue revokes the previous leases whose grace period has ended
+func (r *DynamicSecretReconciler) revokeDue(d *apiv1.DynamicSecret) error {
+	if d.Status == nil || len(d.Status.PendingRevocations) == 0 {
+		return nil
+	}
+	var pending []apiv1.LeaseRevocation
+	for _, revocation := range d.Status.PendingRevocations {
+		if time.Now().Before(revocation.RevokeAt.Time) {
+			pending = append(pending, revocation)
+			continue
+		}
+		if err := r.APIClient.Sys().Revoke(revocation.LeaseID); err != nil {
+			return err
+		}
+	}
+	if len(pending) == len(d.Status.PendingRevocations) {
+		return nil
+	}
+	d.Status.PendingRevocations = pending
+	return r.Update(context.Background(), d)
+}
+
+// nextReconcile returns when the lease must be renewed or a previous lease revoked
+func (r *DynamicSecretReconciler) nextReconcile(d *apiv1.DynamicSecret) time.Duration {
+	var next time.Time
+	if d.Status.LeaseID != "" && d.Status.LeaseExpiry != nil {
+		next = d.Status.LeaseExpiry.Add(-renewThreshold(d))
+	}
+	for _, revocation := range d.Status.PendingRevocations {
+		if next.IsZero() || revocation.RevokeAt.Time.Before(next) {
+			next = revocation.RevokeAt.Time
+		}
+	}
+	if next.IsZero() {
+		return 0
+	}
+	if wait := time.Until(next); wait > time.Second {
+		return wait
+	}
+	return time.Second
+}
+
+// IsUptoDate returns true if the Secret holds credentials for the current spec
+func (r *DynamicSecretReconciler) IsUptoDate(d *apiv1.DynamicSecret) (bool, error) {
+	hash, err := d.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating dynamicsecret hash: %v", err)
+	}
+	if d.Status == nil {
+		return false, nil
+	}
+	if d.Status.Hash != hash {
+		return false, nil
+	}
+	secret := &corev1.Secret{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: d.Status.SecretName, Namespace: d.GetNamespace()}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
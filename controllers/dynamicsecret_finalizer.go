@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *DynamicSecretReconciler) addFinalizer(instance *apiv1.DynamicSecret) error {
+	instance.AddFinalizer(apiv1.DynamicSecretFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *DynamicSecretReconciler) handleFinalizer(d *apiv1.DynamicSecret) error {
+	if !d.HasFinalizer(apiv1.DynamicSecretFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(d); err != nil {
+		return err
+	}
+	d.RemoveFinalizer(apiv1.DynamicSecretFinalizer)
+	return r.Update(context.Background(), d)
+}
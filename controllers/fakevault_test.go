@@ -0,0 +1,174 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// fakeVault is a minimal in-memory vault HTTP API recording writes and deletes
+type fakeVault struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	data      map[string]map[string]interface{}
+	responses map[string]map[string]interface{}
+	served    map[string]map[string]interface{}
+	deletes   []string
+}
+
+// newFakeVault starts a fake vault, callers must Close it
+func newFakeVault() *fakeVault {
+	v := &fakeVault{data: map[string]map[string]interface{}{}, responses: map[string]map[string]interface{}{}, served: map[string]map[string]interface{}{}}
+	v.Server = httptest.NewServer(http.HandlerFunc(v.handle))
+	return v
+}
+
+func (v *fakeVault) handle(w http.ResponseWriter, req *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v1/")
+	switch req.Method {
+	case http.MethodPut, http.MethodPost:
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v.data[path] = body
+		if data, ok := v.responses[path]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		delete(v.data, path)
+		v.deletes = append(v.deletes, path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		data, ok := v.data[path]
+		if served, found := v.served[path]; found {
+			data, ok = served, true
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (v *fakeVault) get(path string) (map[string]interface{}, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	data, ok := v.data[path]
+	return data, ok
+}
+
+// set preloads the data returned when reading path
+func (v *fakeVault) set(path string, data map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.data[path] = data
+}
+
+// respond sets the data returned when writing to path
+func (v *fakeVault) respond(path string, data map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.responses[path] = data
+}
+
+// serve sets the data returned when reading path, whatever is written to it
+func (v *fakeVault) serve(path string, data map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.served[path] = data
+}
+
+func (v *fakeVault) deleted(path string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, p := range v.deletes {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// newScheme returns a scheme holding the kubernetes and vault controller types
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+// newFakeClient returns a fake kubernetes client holding the vault config pointing at the fake vault
+func newFakeClient(t *testing.T, vault *fakeVault, objs ...runtime.Object) client.Client {
+	scheme := newScheme(t)
+	config := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: apiv1.WatchNamespace},
+		Data: map[string]string{
+			"address": vault.URL,
+			"token":   "root",
+		},
+	}
+	return fake.NewFakeClientWithScheme(scheme, append(objs, config)...)
+}
+
+// newCreatedSysAuth returns a SysAuth as left by the sysauth controller after mounting
+func newCreatedSysAuth(name, authType string) *apiv1.SysAuth {
+	return &apiv1.SysAuth{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: apiv1.WatchNamespace},
+		Spec:       &apiv1.SysAuthSpec{Path: name, Type: authType},
+		Status:     &apiv1.SysAuthStatus{State: apiv1.SysAuthCreatedState},
+	}
+}
+
+// newCreatedSecretsEngine returns a SecretsEngine as left by the secretsengine controller after mounting
+func newCreatedSecretsEngine(name, engineType string) *apiv1.SecretsEngine {
+	return &apiv1.SecretsEngine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: apiv1.WatchNamespace},
+		Spec:       &apiv1.SecretsEngineSpec{Path: name, Type: engineType},
+		Status:     &apiv1.SecretsEngineStatus{State: apiv1.SecretsEngineCreatedState, Path: name},
+	}
+}
@@ -0,0 +1,202 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// GCPAuthRoleReconciler reconciles a GCPAuthRole object
+type GCPAuthRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=gcpauthroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=gcpauthroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *GCPAuthRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("gcpauthrole", req.NamespacedName)
+
+	role := &apiv1.GCPAuthRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking gcpauthrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating gcp auth role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating gcpauthrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.GCPAuthRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "gcp auth role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *GCPAuthRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *GCPAuthRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.GCPAuthRole{}).
+		Complete(r)
+}
+
+func (r *GCPAuthRoleReconciler) delete(g *apiv1.GCPAuthRole) error {
+	r.Log.Info(fmt.Sprintf("deleting gcp auth role %s", g.GetName()))
+	if g.Status == nil || g.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/role/%s", g.Status.Path, g.Spec.Name))
+	return err
+}
+
+func (r *GCPAuthRoleReconciler) put(g *apiv1.GCPAuthRole) error {
+	path, err := getAuthMountPath(r.Client, g.GetNamespace(), g.Spec.SysAuthRef, "gcp")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"type":                   g.Spec.Type,
+		"bound_service_accounts": g.Spec.BoundServiceAccounts,
+		"bound_projects":         g.Spec.BoundProjects,
+		"bound_zones":            g.Spec.BoundZones,
+		"bound_regions":          g.Spec.BoundRegions,
+		"bound_labels":           g.Spec.BoundLabels,
+		"token_policies":         g.Spec.TokenPolicies,
+		"token_ttl":              g.Spec.TokenTTL,
+		"token_max_ttl":          g.Spec.TokenMaxTTL,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s", path, g.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := g.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.GCPAuthRoleUpdatedState
+	if !g.IsCreated() {
+		state = apiv1.GCPAuthRoleCreatedState
+	}
+	g.Status = &apiv1.GCPAuthRoleStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), g)
+}
+
+// IsUptoDate returns true if a gcp auth role is current
+func (r *GCPAuthRoleReconciler) IsUptoDate(g *apiv1.GCPAuthRole) (bool, error) {
+	hash, err := g.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating gcpauthrole hash: %v", err)
+	}
+	if g.Status == nil {
+		return false, nil
+	}
+	if g.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
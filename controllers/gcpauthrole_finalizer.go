@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *GCPAuthRoleReconciler) addFinalizer(instance *apiv1.GCPAuthRole) error {
+	instance.AddFinalizer(apiv1.GCPAuthRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *GCPAuthRoleReconciler) handleFinalizer(g *apiv1.GCPAuthRole) error {
+	if !g.HasFinalizer(apiv1.GCPAuthRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(g); err != nil {
+		return err
+	}
+	g.RemoveFinalizer(apiv1.GCPAuthRoleFinalizer)
+	return r.Update(context.Background(), g)
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// gitHubMapPath returns the API path mapping a github team or user to policies,
+// kind is either teams or users
+func gitHubMapPath(path, kind, name string) string {
+	return fmt.Sprintf("auth/%s/map/%s/%s", path, kind, name)
+}
+
+// putGitHubMap maps a github team or user to a comma separated list of policies
+func putGitHubMap(vclient *vaultapi.Client, path, kind, name string, policies []string) error {
+	_, err := vclient.Logical().Write(gitHubMapPath(path, kind, name), map[string]interface{}{
+		"value": strings.Join(policies, ","),
+	})
+	return err
+}
+
+// deleteGitHubMap removes the policy mapping of a github team or user
+func deleteGitHubMap(vclient *vaultapi.Client, path, kind, name string) error {
+	_, err := vclient.Logical().Delete(gitHubMapPath(path, kind, name))
+	return err
+}
@@ -0,0 +1,169 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// GitHubAuthConfigReconciler reconciles a GitHubAuthConfig object
+type GitHubAuthConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=githubauthconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=githubauthconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *GitHubAuthConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("githubauthconfig", req.NamespacedName)
+
+	authConfig := &apiv1.GitHubAuthConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, authConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for auth/<path>/config, the config is removed along with the mount
+	if authConfig.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(authConfig)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking githubauthconfig IsUptoDate: %v", err)
+	}
+
+	if !authConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing github auth config for sysauth %v", authConfig.Spec.SysAuthRef))
+		if err := r.put(authConfig); err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing githubauthconfig: %v", err)
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "updated", "github auth config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *GitHubAuthConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *GitHubAuthConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.GitHubAuthConfig{}).
+		Complete(r)
+}
+
+func (r *GitHubAuthConfigReconciler) put(g *apiv1.GitHubAuthConfig) error {
+	path, err := getAuthMountPath(r.Client, g.GetNamespace(), g.Spec.SysAuthRef, "github")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"organization":  g.Spec.Organization,
+		"base_url":      g.Spec.BaseURL,
+		"token_ttl":     g.Spec.TokenTTL,
+		"token_max_ttl": g.Spec.TokenMaxTTL,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/config", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := g.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.GitHubAuthConfigUpdatedState
+	if !g.IsCreated() {
+		state = apiv1.GitHubAuthConfigCreatedState
+	}
+	g.Status = &apiv1.GitHubAuthConfigStatus{
+		Hash:  hash,
+		State: state,
+	}
+	return r.Update(context.Background(), g)
+}
+
+// IsUptoDate returns true if a github auth config is current
+func (r *GitHubAuthConfigReconciler) IsUptoDate(g *apiv1.GitHubAuthConfig) (bool, error) {
+	hash, err := g.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating githubauthconfig hash: %v", err)
+	}
+	if g.Status == nil {
+		return false, nil
+	}
+	if g.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,235 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// GitHubTeamReconciler reconciles a GitHubTeam object
+type GitHubTeamReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=githubteams,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=githubteams/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=policies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *GitHubTeamReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("githubteam", req.NamespacedName)
+
+	mapping := &apiv1.GitHubTeam{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, mapping)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if mapping.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(mapping)
+		if err != nil {
+			r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(mapping, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	policies, missing, err := resolvePolicies(r.Client, mapping.GetNamespace(), mapping.Spec.Policies, mapping.Spec.PolicyRefs)
+	if err != nil {
+		r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to resolve policy refs: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when resolving policy refs: %v", err)
+	}
+	if len(missing) > 0 {
+		r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("policy refs not ready: %s", strings.Join(missing, ", ")))
+	}
+
+	isUptoDate, err := r.IsUptoDate(mapping, policies, missing)
+	if err != nil {
+		r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking githubteam IsUptoDate: %v", err)
+	}
+
+	if !mapping.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating github team mapping %v", mapping.Spec.Name))
+		if err := r.put(mapping, policies, missing); err != nil {
+			if !mapping.IsCreated() {
+				r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating githubteam: %v", err)
+		}
+
+		if !mapping.HasFinalizer(apiv1.GitHubTeamFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(mapping); err != nil {
+				r.Recorder.Event(mapping, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(mapping, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(mapping, corev1.EventTypeNormal, "updated", "github team mapping is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *GitHubTeamReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *GitHubTeamReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.GitHubTeam{}).
+		Watches(&source.Kind{Type: &apiv1.Policy{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.policyToTeams),
+		}).
+		Complete(r)
+}
+
+// policyToTeams enqueues the team mappings referencing a Policy so they pick up its readiness
+func (r *GitHubTeamReconciler) policyToTeams(o handler.MapObject) []reconcile.Request {
+	mappings := &apiv1.GitHubTeamList{}
+	if err := r.List(context.Background(), mappings, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list github team mappings")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, mapping := range mappings.Items {
+		if mapping.Spec == nil {
+			continue
+		}
+		for _, ref := range mapping.Spec.PolicyRefs {
+			if ref == o.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: mapping.GetName(), Namespace: mapping.GetNamespace()},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+func (r *GitHubTeamReconciler) delete(g *apiv1.GitHubTeam) error {
+	r.Log.Info(fmt.Sprintf("deleting github team mapping %s", g.GetName()))
+	if g.Status == nil || g.Status.Path == "" {
+		return nil
+	}
+	return deleteGitHubMap(r.APIClient, g.Status.Path, "teams", g.Spec.Name)
+}
+
+func (r *GitHubTeamReconciler) put(g *apiv1.GitHubTeam, policies, missing []string) error {
+	path, err := getAuthMountPath(r.Client, g.GetNamespace(), g.Spec.SysAuthRef, "github")
+	if err != nil {
+		return err
+	}
+	if err := putGitHubMap(r.APIClient, path, "teams", g.Spec.Name, policies); err != nil {
+		return err
+	}
+	hash, err := g.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.GitHubTeamUpdatedState
+	if !g.IsCreated() {
+		state = apiv1.GitHubTeamCreatedState
+	}
+	g.Status = &apiv1.GitHubTeamStatus{
+		Hash:              hash,
+		State:             state,
+		Path:              path,
+		Policies:          policies,
+		MissingPolicyRefs: missing,
+	}
+	return r.Update(context.Background(), g)
+}
+
+// IsUptoDate returns true if a github team mapping is current
+func (r *GitHubTeamReconciler) IsUptoDate(g *apiv1.GitHubTeam, policies, missing []string) (bool, error) {
+	hash, err := g.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating githubteam hash: %v", err)
+	}
+	if g.Status == nil {
+		return false, nil
+	}
+	if g.Status.Hash != hash {
+		return false, nil
+	}
+	if !equalStrings(g.Status.Policies, policies) || !equalStrings(g.Status.MissingPolicyRefs, missing) {
+		return false, nil
+	}
+	return true, nil
+}
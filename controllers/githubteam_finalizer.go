@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *GitHubTeamReconciler) addFinalizer(instance *apiv1.GitHubTeam) error {
+	instance.AddFinalizer(apiv1.GitHubTeamFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *GitHubTeamReconciler) handleFinalizer(g *apiv1.GitHubTeam) error {
+	if !g.HasFinalizer(apiv1.GitHubTeamFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(g); err != nil {
+		return err
+	}
+	g.RemoveFinalizer(apiv1.GitHubTeamFinalizer)
+	return r.Update(context.Background(), g)
+}
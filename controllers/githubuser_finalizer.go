@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *GitHubUserReconciler) addFinalizer(instance *apiv1.GitHubUser) error {
+	instance.AddFinalizer(apiv1.GitHubUserFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *GitHubUserReconciler) handleFinalizer(g *apiv1.GitHubUser) error {
+	if !g.HasFinalizer(apiv1.GitHubUserFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(g); err != nil {
+		return err
+	}
+	g.RemoveFinalizer(apiv1.GitHubUserFinalizer)
+	return r.Update(context.Background(), g)
+}
@@ -0,0 +1,343 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// IdentityEntityReconciler reconciles a IdentityEntity object
+type IdentityEntityReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=identityentities,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=identityentities/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *IdentityEntityReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("identityentity", req.NamespacedName)
+
+	entity := &apiv1.IdentityEntity{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, entity)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if entity.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(entity)
+		if err != nil {
+			r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(entity, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	if err := entity.Spec.Validate(); err != nil {
+		r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(entity)
+	if err != nil {
+		r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking identityentity IsUptoDate: %v", err)
+	}
+
+	if !entity.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating identity entity %v", entity.Spec.Name))
+		if err := r.put(entity); err != nil {
+			if !entity.IsCreated() {
+				r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating identityentity: %v", err)
+		}
+
+		if !entity.HasFinalizer(apiv1.IdentityEntityFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(entity); err != nil {
+				r.Recorder.Event(entity, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(entity, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(entity, corev1.EventTypeNormal, "updated", fmt.Sprintf("identity entity %s is updated", entity.Status.EntityID))
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *IdentityEntityReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *IdentityEntityReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.IdentityEntity{}).
+		Complete(r)
+}
+
+// delete removes the entity, vault removes its aliases along with it
+func (r *IdentityEntityReconciler) delete(i *apiv1.IdentityEntity) error {
+	r.Log.Info(fmt.Sprintf("deleting identity entity %s", i.GetName()))
+	if i.Status == nil || i.Status.EntityID == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("identity/entity/id/%s", i.Status.EntityID))
+	return err
+}
+
+// lookup returns the entity of a name, nil if there is none
+func (r *IdentityEntityReconciler) lookup(name string) (*vaultapi.Secret, error) {
+	secret, err := r.APIClient.Logical().Read(fmt.Sprintf("identity/entity/name/%s", name))
+	if err != nil {
+		return nil, fmt.Errorf("error when reading identity entity %s: %v", name, err)
+	}
+	return secret, nil
+}
+
+// rename moves the previously written entity to the new name of the spec, keeping its ID and aliases.
+// An error is returned when another entity already holds the new name, neither entity is changed
+func (r *IdentityEntityReconciler) rename(i *apiv1.IdentityEntity) error {
+	if i.Status == nil || i.Status.EntityID == "" {
+		return nil
+	}
+	previous, err := r.APIClient.Logical().Read(fmt.Sprintf("identity/entity/id/%s", i.Status.EntityID))
+	if err != nil {
+		return fmt.Errorf("error when reading identity entity %s: %v", i.Status.EntityID, err)
+	}
+	if previous == nil {
+		return nil
+	}
+	name, _ := previous.Data["name"].(string)
+	if name == i.Spec.Name {
+		return nil
+	}
+	current, err := r.lookup(i.Spec.Name)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		return fmt.Errorf("identity entity %s already exists, refusing to rename %s to it", i.Spec.Name, name)
+	}
+	r.Log.Info(fmt.Sprintf("renaming identity entity %s to %s", name, i.Spec.Name))
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("identity/entity/id/%s", i.Status.EntityID), map[string]interface{}{
+		"name": i.Spec.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("error when renaming identity entity %s: %v", name, err)
+	}
+	return nil
+}
+
+func (r *IdentityEntityReconciler) put(i *apiv1.IdentityEntity) error {
+	// resolve the accessors first so a missing auth mount does not leave a half written entity
+	accessors := map[string]string{}
+	for _, alias := range i.Spec.Aliases {
+		accessor, err := getAuthMountAccessor(r.Client, r.APIClient, i.GetNamespace(), alias.SysAuthRef)
+		if err != nil {
+			return err
+		}
+		accessors[alias.SysAuthRef] = accessor
+	}
+
+	if err := r.rename(i); err != nil {
+		return err
+	}
+	_, err := r.APIClient.Logical().Write(fmt.Sprintf("identity/entity/name/%s", i.Spec.Name), map[string]interface{}{
+		"policies": i.Spec.Policies,
+		"metadata": i.Spec.Metadata,
+		"disabled": i.Spec.Disabled,
+	})
+	if err != nil {
+		return err
+	}
+	secret, err := r.lookup(i.Spec.Name)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return fmt.Errorf("identity entity %s not found after writing it", i.Spec.Name)
+	}
+	entityID, _ := secret.Data["id"].(string)
+
+	// existing aliases keyed by mount accessor, an entity has at most one alias per mount
+	existing := map[string]map[string]interface{}{}
+	items, _ := secret.Data["aliases"].([]interface{})
+	for _, item := range items {
+		if alias, ok := item.(map[string]interface{}); ok {
+			accessor, _ := alias["mount_accessor"].(string)
+			existing[accessor] = alias
+		}
+	}
+
+	// only aliases created by the controller are changed
+	owned := map[string]bool{}
+	if i.IsCreated() && i.Status.EntityID == entityID {
+		for _, alias := range i.Status.Aliases {
+			owned[alias.ID] = true
+		}
+	}
+
+	var aliases []apiv1.IdentityEntityAliasStatus
+	for _, alias := range i.Spec.Aliases {
+		accessor := accessors[alias.SysAuthRef]
+		data := map[string]interface{}{
+			"name":           alias.Name,
+			"canonical_id":   entityID,
+			"mount_accessor": accessor,
+		}
+		var aliasID string
+		if current, ok := existing[accessor]; ok {
+			aliasID, _ = current["id"].(string)
+			if name, _ := current["name"].(string); name != alias.Name {
+				if !owned[aliasID] {
+					return fmt.Errorf("alias %s on %s was not created by the controller, refusing to rename it to %s", name, alias.SysAuthRef, alias.Name)
+				}
+				_, err := r.APIClient.Logical().Write(fmt.Sprintf("identity/entity-alias/id/%s", aliasID), data)
+				if err != nil {
+					return fmt.Errorf("error when updating alias %s: %v", alias.Name, err)
+				}
+			}
+		} else {
+			created, err := r.APIClient.Logical().Write("identity/entity-alias", data)
+			if err != nil {
+				return fmt.Errorf("error when creating alias %s: %v", alias.Name, err)
+			}
+			if created == nil {
+				return fmt.Errorf("empty response when creating alias %s", alias.Name)
+			}
+			aliasID, _ = created.Data["id"].(string)
+		}
+		aliases = append(aliases, apiv1.IdentityEntityAliasStatus{
+			ID:            aliasID,
+			Name:          alias.Name,
+			SysAuthRef:    alias.SysAuthRef,
+			MountAccessor: accessor,
+		})
+	}
+
+	// aliases removed from the spec are deleted, aliases added outside of the controller are kept
+	if i.IsCreated() && i.Status.EntityID == entityID {
+		for _, previous := range i.Status.Aliases {
+			kept := false
+			for _, alias := range aliases {
+				kept = kept || alias.ID == previous.ID
+			}
+			if kept {
+				continue
+			}
+			_, err := r.APIClient.Logical().Delete(fmt.Sprintf("identity/entity-alias/id/%s", previous.ID))
+			if err != nil {
+				return fmt.Errorf("error when deleting alias %s: %v", previous.Name, err)
+			}
+		}
+	}
+
+	hash, err := i.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.IdentityEntityUpdatedState
+	if !i.IsCreated() {
+		state = apiv1.IdentityEntityCreatedState
+	}
+	i.Status = &apiv1.IdentityEntityStatus{
+		Hash:     hash,
+		State:    state,
+		EntityID: entityID,
+		Aliases:  aliases,
+	}
+	return r.Update(context.Background(), i)
+}
+
+// IsUptoDate returns true if the entity was written from the current spec and still exists with the same ID
+func (r *IdentityEntityReconciler) IsUptoDate(i *apiv1.IdentityEntity) (bool, error) {
+	hash, err := i.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating identityentity hash: %v", err)
+	}
+	if i.Status == nil || i.Status.Hash != hash {
+		return false, nil
+	}
+	secret, err := r.lookup(i.Spec.Name)
+	if err != nil {
+		return false, err
+	}
+	if secret == nil {
+		return false, nil
+	}
+	if id, _ := secret.Data["id"].(string); id != i.Status.EntityID {
+		return false, nil
+	}
+	return true, nil
+}
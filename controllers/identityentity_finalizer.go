@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *IdentityEntityReconciler) addFinalizer(instance *apiv1.IdentityEntity) error {
+	instance.AddFinalizer(apiv1.IdentityEntityFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *IdentityEntityReconciler) handleFinalizer(i *apiv1.IdentityEntity) error {
+	if !i.HasFinalizer(apiv1.IdentityEntityFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(i); err != nil {
+		return err
+	}
+	i.RemoveFinalizer(apiv1.IdentityEntityFinalizer)
+	return r.Update(context.Background(), i)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestIdentityEntityRename(t *testing.T) {
+	tests := []struct {
+		name    string
+		taken   bool
+		renamed bool
+	}{
+		{name: "renames the previous entity", taken: false, renamed: true},
+		{name: "refuses to rename when the name is taken", taken: true, renamed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := newFakeVault()
+			defer vault.Close()
+			vault.set("identity/entity/id/previous", map[string]interface{}{"id": "previous", "name": "old"})
+			if tt.taken {
+				vault.set("identity/entity/name/new", map[string]interface{}{"id": "other", "name": "new"})
+			}
+			entity := &apiv1.IdentityEntity{
+				ObjectMeta: metav1.ObjectMeta{Name: "entity", Namespace: apiv1.WatchNamespace},
+				Spec:       &apiv1.IdentityEntitySpec{Name: "new", Policies: []string{"dev"}},
+				Status: &apiv1.IdentityEntityStatus{
+					Hash:     "old",
+					State:    apiv1.IdentityEntityCreatedState,
+					EntityID: "previous",
+				},
+			}
+			c := newFakeClient(t, vault, entity)
+			r := &IdentityEntityReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "entity", Namespace: apiv1.WatchNamespace}}
+
+			_, err := r.Reconcile(req)
+			if tt.taken && err == nil {
+				t.Fatal("expected an error when the name is taken")
+			}
+			if !tt.taken && err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			data, _ := vault.get("identity/entity/id/previous")
+			if tt.renamed && data["name"] != "new" {
+				t.Errorf("expected the previous entity to be renamed, got %v", data)
+			}
+			if !tt.renamed && data["name"] != "old" {
+				t.Errorf("expected the previous entity to be left alone, got %v", data)
+			}
+			if vault.deleted("identity/entity/id/previous") {
+				t.Error("expected the previous entity not to be deleted")
+			}
+			if other, _ := vault.get("identity/entity/name/new"); tt.taken && other["id"] != "other" {
+				t.Errorf("expected the other entity to be left alone, got %v", other)
+			}
+		})
+	}
+}
+
+func TestIdentityEntityOutsideAlias(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	vault.set("sys/auth", map[string]interface{}{
+		"userpass/": map[string]interface{}{"type": "userpass", "accessor": "auth_userpass_1"},
+	})
+	vault.serve("identity/entity/name/dev", map[string]interface{}{
+		"id":   "entity",
+		"name": "dev",
+		"aliases": []interface{}{
+			map[string]interface{}{"id": "outside", "name": "someone", "mount_accessor": "auth_userpass_1"},
+		},
+	})
+	entity := &apiv1.IdentityEntity{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.IdentityEntitySpec{
+			Name:    "dev",
+			Aliases: []apiv1.IdentityEntityAlias{{Name: "dev", SysAuthRef: "userpass"}},
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSysAuth("userpass", "userpass"), entity)
+	r := &IdentityEntityReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "dev", Namespace: apiv1.WatchNamespace}}
+
+	if _, err := r.Reconcile(req); err == nil {
+		t.Fatal("expected an error for an alias created outside of the controller")
+	}
+	if _, ok := vault.get("identity/entity-alias/id/outside"); ok {
+		t.Error("expected the outside alias not to be renamed")
+	}
+}
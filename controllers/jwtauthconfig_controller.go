@@ -0,0 +1,195 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// JWTAuthConfigReconciler reconciles a JWTAuthConfig object
+type JWTAuthConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=jwtauthconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=jwtauthconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *JWTAuthConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("jwtauthconfig", req.NamespacedName)
+
+	authConfig := &apiv1.JWTAuthConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, authConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for auth/<path>/config, the config is removed along with the mount
+	if authConfig.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+	if err := authConfig.Spec.Validate(); err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	clientSecret, clientSecretVersion, err := r.getClientSecret(authConfig)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get oidc client secret: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting oidc client secret: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(authConfig, clientSecretVersion)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking jwtauthconfig IsUptoDate: %v", err)
+	}
+
+	if !authConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing jwt auth config for sysauth %v", authConfig.Spec.SysAuthRef))
+		if err := r.put(authConfig, clientSecret, clientSecretVersion); err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing jwtauthconfig: %v", err)
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "updated", "jwt auth config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *JWTAuthConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *JWTAuthConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.JWTAuthConfig{}).
+		Complete(r)
+}
+
+func (r *JWTAuthConfigReconciler) getClientSecret(j *apiv1.JWTAuthConfig) (string, string, error) {
+	if j.Spec.OIDCClientSecret == nil {
+		return "", "", nil
+	}
+	return getSecretValue(r.Client, j.GetNamespace(), j.Spec.OIDCClientSecret)
+}
+
+func (r *JWTAuthConfigReconciler) put(j *apiv1.JWTAuthConfig, clientSecret, clientSecretVersion string) error {
+	path, err := getAuthMountPath(r.Client, j.GetNamespace(), j.Spec.SysAuthRef, "jwt", "oidc")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"oidc_discovery_url":     j.Spec.OIDCDiscoveryURL,
+		"oidc_discovery_ca_pem":  j.Spec.OIDCDiscoveryCAPEM,
+		"oidc_client_id":         j.Spec.OIDCClientID,
+		"jwks_url":               j.Spec.JWKSURL,
+		"jwks_ca_pem":            j.Spec.JWKSCAPEM,
+		"jwt_validation_pubkeys": j.Spec.JWTValidationPubKeys,
+		"bound_issuer":           j.Spec.BoundIssuer,
+		"default_role":           j.Spec.DefaultRole,
+	}
+	if clientSecret != "" {
+		data["oidc_client_secret"] = clientSecret
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/config", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := j.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.JWTAuthConfigUpdatedState
+	if !j.IsCreated() {
+		state = apiv1.JWTAuthConfigCreatedState
+	}
+	j.Status = &apiv1.JWTAuthConfigStatus{
+		Hash:                    hash,
+		State:                   state,
+		OIDCClientSecretVersion: clientSecretVersion,
+	}
+	return r.Update(context.Background(), j)
+}
+
+// IsUptoDate returns true if a jwt auth config is current
+func (r *JWTAuthConfigReconciler) IsUptoDate(j *apiv1.JWTAuthConfig, clientSecretVersion string) (bool, error) {
+	hash, err := j.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating jwtauthconfig hash: %v", err)
+	}
+	if j.Status == nil {
+		return false, nil
+	}
+	if j.Status.Hash != hash || j.Status.OIDCClientSecretVersion != clientSecretVersion {
+		return false, nil
+	}
+	return true, nil
+}
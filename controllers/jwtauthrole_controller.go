@@ -0,0 +1,215 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// JWTAuthRoleReconciler reconciles a JWTAuthRole object
+type JWTAuthRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=jwtauthroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=jwtauthroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *JWTAuthRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("jwtauthrole", req.NamespacedName)
+
+	role := &apiv1.JWTAuthRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	if err := role.Spec.Validate(); err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking jwtauthrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating jwt auth role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating jwtauthrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.JWTAuthRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "jwt auth role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *JWTAuthRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *JWTAuthRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.JWTAuthRole{}).
+		Complete(r)
+}
+
+func (r *JWTAuthRoleReconciler) delete(j *apiv1.JWTAuthRole) error {
+	r.Log.Info(fmt.Sprintf("deleting jwt auth role %s", j.GetName()))
+	if j.Status == nil || j.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/role/%s", j.Status.Path, j.Spec.Name))
+	return err
+}
+
+func (r *JWTAuthRoleReconciler) put(j *apiv1.JWTAuthRole) error {
+	path, err := getAuthMountPath(r.Client, j.GetNamespace(), j.Spec.SysAuthRef, "jwt", "oidc")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"bound_audiences":       j.Spec.BoundAudiences,
+		"bound_subject":         j.Spec.BoundSubject,
+		"bound_claims":          j.Spec.BoundClaims,
+		"claim_mappings":        j.Spec.ClaimMappings,
+		"user_claim":            j.Spec.UserClaim,
+		"groups_claim":          j.Spec.GroupsClaim,
+		"oidc_scopes":           j.Spec.OIDCScopes,
+		"allowed_redirect_uris": j.Spec.AllowedRedirectURIs,
+		"token_policies":        j.Spec.TokenPolicies,
+		"token_ttl":             j.Spec.TokenTTL,
+		"token_max_ttl":         j.Spec.TokenMaxTTL,
+	}
+	if j.Spec.RoleType != "" {
+		data["role_type"] = j.Spec.RoleType
+	}
+	if j.Spec.BoundClaimsType != "" {
+		data["bound_claims_type"] = j.Spec.BoundClaimsType
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s", path, j.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := j.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.JWTAuthRoleUpdatedState
+	if !j.IsCreated() {
+		state = apiv1.JWTAuthRoleCreatedState
+	}
+	j.Status = &apiv1.JWTAuthRoleStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), j)
+}
+
+// IsUptoDate returns true if a jwt auth role is current
+func (r *JWTAuthRoleReconciler) IsUptoDate(j *apiv1.JWTAuthRole) (bool, error) {
+	hash, err := j.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating jwtauthrole hash: %v", err)
+	}
+	if j.Status == nil {
+		return false, nil
+	}
+	if j.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *JWTAuthRoleReconciler) addFinalizer(instance *apiv1.JWTAuthRole) error {
+	instance.AddFinalizer(apiv1.JWTAuthRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *JWTAuthRoleReconciler) handleFinalizer(j *apiv1.JWTAuthRole) error {
+	if !j.HasFinalizer(apiv1.JWTAuthRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(j); err != nil {
+		return err
+	}
+	j.RemoveFinalizer(apiv1.JWTAuthRoleFinalizer)
+	return r.Update(context.Background(), j)
+}
@@ -0,0 +1,223 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// KubernetesAuthConfigReconciler reconciles a KubernetesAuthConfig object
+type KubernetesAuthConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+	// InClusterConfig is the controller rest config when running in-cluster, nil otherwise
+	InClusterConfig *rest.Config
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kubernetesauthconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kubernetesauthconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *KubernetesAuthConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("kubernetesauthconfig", req.NamespacedName)
+
+	authConfig := &apiv1.KubernetesAuthConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, authConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for auth/<path>/config, the config is removed along with the mount
+	if authConfig.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	jwt, jwtVersion, err := r.getTokenReviewerJWT(authConfig)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get token reviewer jwt: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting token reviewer jwt: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(authConfig, jwtVersion)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking kubernetesauthconfig IsUptoDate: %v", err)
+	}
+
+	if !authConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing kubernetes auth config for sysauth %v", authConfig.Spec.SysAuthRef))
+		if err := r.put(authConfig, jwt, jwtVersion); err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing kubernetesauthconfig: %v", err)
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "updated", "kubernetes auth config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KubernetesAuthConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *KubernetesAuthConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.KubernetesAuthConfig{}).
+		Complete(r)
+}
+
+func (r *KubernetesAuthConfigReconciler) getTokenReviewerJWT(k *apiv1.KubernetesAuthConfig) (string, string, error) {
+	if k.Spec.TokenReviewerJWT == nil {
+		return "", "", nil
+	}
+	return getSecretValue(r.Client, k.GetNamespace(), k.Spec.TokenReviewerJWT)
+}
+
+// kubernetesHost returns the configured host and CA, falling back to the in-cluster values
+func (r *KubernetesAuthConfigReconciler) kubernetesHost(k *apiv1.KubernetesAuthConfig) (string, string, error) {
+	host := k.Spec.KubernetesHost
+	caCert := k.Spec.KubernetesCACert
+	if r.InClusterConfig == nil {
+		return host, caCert, nil
+	}
+	if host == "" {
+		host = r.InClusterConfig.Host
+	}
+	if caCert == "" {
+		caData := r.InClusterConfig.TLSClientConfig.CAData
+		if len(caData) == 0 && r.InClusterConfig.TLSClientConfig.CAFile != "" {
+			var err error
+			caData, err = ioutil.ReadFile(r.InClusterConfig.TLSClientConfig.CAFile)
+			if err != nil {
+				return "", "", fmt.Errorf("error when reading in-cluster CA: %v", err)
+			}
+		}
+		caCert = string(caData)
+	}
+	return host, caCert, nil
+}
+
+func (r *KubernetesAuthConfigReconciler) put(k *apiv1.KubernetesAuthConfig, jwt, jwtVersion string) error {
+	path, err := getAuthMountPath(r.Client, k.GetNamespace(), k.Spec.SysAuthRef, "kubernetes")
+	if err != nil {
+		return err
+	}
+	host, caCert, err := r.kubernetesHost(k)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return fmt.Errorf("kubernetes_host is required when not running in-cluster")
+	}
+	data := map[string]interface{}{
+		"kubernetes_host":        host,
+		"kubernetes_ca_cert":     caCert,
+		"issuer":                 k.Spec.Issuer,
+		"disable_iss_validation": k.Spec.DisableISSValidation,
+	}
+	if jwt != "" {
+		data["token_reviewer_jwt"] = jwt
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/config", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := k.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.KubernetesAuthConfigUpdatedState
+	if !k.IsCreated() {
+		state = apiv1.KubernetesAuthConfigCreatedState
+	}
+	k.Status = &apiv1.KubernetesAuthConfigStatus{
+		Hash:                    hash,
+		State:                   state,
+		KubernetesHost:          host,
+		TokenReviewerJWTVersion: jwtVersion,
+	}
+	return r.Update(context.Background(), k)
+}
+
+// IsUptoDate returns true if a kubernetes auth config is current
+func (r *KubernetesAuthConfigReconciler) IsUptoDate(k *apiv1.KubernetesAuthConfig, jwtVersion string) (bool, error) {
+	hash, err := k.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating kubernetesauthconfig hash: %v", err)
+	}
+	if k.Status == nil {
+		return false, nil
+	}
+	if k.Status.Hash != hash || k.Status.TokenReviewerJWTVersion != jwtVersion {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,248 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// KubernetesAuthRoleReconciler reconciles a KubernetesAuthRole object
+type KubernetesAuthRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kubernetesauthroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kubernetesauthroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=policies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *KubernetesAuthRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("kubernetesauthrole", req.NamespacedName)
+
+	role := &apiv1.KubernetesAuthRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	policies, missing, err := resolvePolicies(r.Client, role.GetNamespace(), role.Spec.TokenPolicies, role.Spec.PolicyRefs)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to resolve policy refs: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when resolving policy refs: %v", err)
+	}
+	if len(missing) > 0 {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("policy refs not ready: %s", strings.Join(missing, ", ")))
+	}
+
+	isUptoDate, err := r.IsUptoDate(role, policies, missing)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking kubernetesauthrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating kubernetes auth role %v", role.Spec.Name))
+		if err := r.put(role, policies, missing); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating kubernetesauthrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.KubernetesAuthRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "kubernetes auth role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KubernetesAuthRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *KubernetesAuthRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.KubernetesAuthRole{}).
+		Watches(&source.Kind{Type: &apiv1.Policy{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.policyToRoles),
+		}).
+		Complete(r)
+}
+
+// policyToRoles enqueues the roles referencing a Policy so they pick up its readiness
+func (r *KubernetesAuthRoleReconciler) policyToRoles(o handler.MapObject) []reconcile.Request {
+	roles := &apiv1.KubernetesAuthRoleList{}
+	if err := r.List(context.Background(), roles, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list kubernetes auth roles")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, role := range roles.Items {
+		if role.Spec == nil {
+			continue
+		}
+		for _, ref := range role.Spec.PolicyRefs {
+			if ref == o.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: role.GetName(), Namespace: role.GetNamespace()},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+func (r *KubernetesAuthRoleReconciler) delete(k *apiv1.KubernetesAuthRole) error {
+	r.Log.Info(fmt.Sprintf("deleting kubernetes auth role %s", k.GetName()))
+	if k.Status == nil || k.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/role/%s", k.Status.Path, k.Spec.Name))
+	return err
+}
+
+func (r *KubernetesAuthRoleReconciler) put(k *apiv1.KubernetesAuthRole, policies, missing []string) error {
+	path, err := getAuthMountPath(r.Client, k.GetNamespace(), k.Spec.SysAuthRef, "kubernetes")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"bound_service_account_names":      k.Spec.BoundServiceAccountNames,
+		"bound_service_account_namespaces": k.Spec.BoundServiceAccountNamespaces,
+		"token_policies":                   policies,
+		"token_ttl":                        k.Spec.TokenTTL,
+		"token_max_ttl":                    k.Spec.TokenMaxTTL,
+		"audience":                         k.Spec.Audience,
+	}
+	if k.Spec.AliasNameSource != "" {
+		data["alias_name_source"] = k.Spec.AliasNameSource
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/role/%s", path, k.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := k.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.KubernetesAuthRoleUpdatedState
+	if !k.IsCreated() {
+		state = apiv1.KubernetesAuthRoleCreatedState
+	}
+	k.Status = &apiv1.KubernetesAuthRoleStatus{
+		Hash:              hash,
+		State:             state,
+		Path:              path,
+		Policies:          policies,
+		MissingPolicyRefs: missing,
+	}
+	return r.Update(context.Background(), k)
+}
+
+// IsUptoDate returns true if a kubernetes auth role is current
+func (r *KubernetesAuthRoleReconciler) IsUptoDate(k *apiv1.KubernetesAuthRole, policies, missing []string) (bool, error) {
+	hash, err := k.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating kubernetesauthrole hash: %v", err)
+	}
+	if k.Status == nil {
+		return false, nil
+	}
+	if k.Status.Hash != hash {
+		return false, nil
+	}
+	if !equalStrings(k.Status.Policies, policies) || !equalStrings(k.Status.MissingPolicyRefs, missing) {
+		return false, nil
+	}
+	return true, nil
+}
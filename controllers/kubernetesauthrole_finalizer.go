@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *KubernetesAuthRoleReconciler) addFinalizer(instance *apiv1.KubernetesAuthRole) error {
+	instance.AddFinalizer(apiv1.KubernetesAuthRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *KubernetesAuthRoleReconciler) handleFinalizer(k *apiv1.KubernetesAuthRole) error {
+	if !k.HasFinalizer(apiv1.KubernetesAuthRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(k); err != nil {
+		return err
+	}
+	k.RemoveFinalizer(apiv1.KubernetesAuthRoleFinalizer)
+	return r.Update(context.Background(), k)
+}
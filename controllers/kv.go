@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// kvPath returns the API path of a KV secret, KV v2 paths are prefixed with data/ or metadata/
+func kvPath(mount, prefix, path string) string {
+	mount = strings.Trim(mount, "/")
+	path = strings.Trim(path, "/")
+	if prefix == "" {
+		return fmt.Sprintf("%s/%s", mount, path)
+	}
+	return fmt.Sprintf("%s/%s/%s", mount, prefix, path)
+}
+
+// readKV returns the data of a KV secret and its version, the latest KV v2 version is read when version is 0
+func readKV(vclient *vaultapi.Client, mount, path string, kvVersion, version int) (map[string]interface{}, int64, error) {
+	if kvVersion == 1 {
+		secret, err := vclient.Logical().Read(kvPath(mount, "", path))
+		if err != nil {
+			return nil, 0, err
+		}
+		if secret == nil {
+			return nil, 0, fmt.Errorf("secret %s not found", kvPath(mount, "", path))
+		}
+		return secret.Data, 0, nil
+	}
+
+	var query map[string][]string
+	if version > 0 {
+		query = map[string][]string{"version": {strconv.Itoa(version)}}
+	}
+	secret, err := vclient.Logical().ReadWithData(kvPath(mount, "data", path), query)
+	if err != nil {
+		return nil, 0, err
+	}
+	if secret == nil || secret.Data["data"] == nil {
+		return nil, 0, fmt.Errorf("secret %s not found or deleted", kvPath(mount, "data", path))
+	}
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return nil, 0, fmt.Errorf("unexpected data in secret %s", kvPath(mount, "data", path))
+	}
+	metadata, _ := secret.Data["metadata"].(map[string]interface{})
+	current, err := toInt64(metadata["version"])
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, current, nil
+}
+
+// kvString returns a KV value as a string, values which are not strings are JSON encoded
+func kvString(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// renderTemplate renders a go template with the KV data, missing keys are an error
+func renderTemplate(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeKV writes a KV v2 secret with check-and-set and returns the written version, cas 0 only writes a new secret
+func writeKV(vclient *vaultapi.Client, mount, path string, data map[string]interface{}, cas int64) (int64, error) {
+	secret, err := vclient.Logical().Write(kvPath(mount, "data", path), map[string]interface{}{
+		"options": map[string]interface{}{"cas": cas},
+		"data":    data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if secret == nil {
+		return 0, fmt.Errorf("empty response when writing %s", kvPath(mount, "data", path))
+	}
+	return toInt64(secret.Data["version"])
+}
+
+// isCASConflict returns true if a KV v2 write failed because of a check-and-set mismatch
+func isCASConflict(err error) bool {
+	respErr, ok := err.(*vaultapi.ResponseError)
+	if !ok || respErr.StatusCode != 400 {
+		return false
+	}
+	for _, e := range respErr.Errors {
+		if strings.Contains(e, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
+// kvCurrentVersion returns the current version of a KV v2 secret, 0 when it doesn't exist
+func kvCurrentVersion(vclient *vaultapi.Client, mount, path string) (int64, error) {
+	secret, err := vclient.Logical().Read(kvPath(mount, "metadata", path))
+	if err != nil {
+		return 0, err
+	}
+	if secret == nil {
+		return 0, nil
+	}
+	return toInt64(secret.Data["current_version"])
+}
+
+// isKVv2 returns true if a KV version 2 engine is mounted at mount, as reported by sys/mounts
+func isKVv2(vclient *vaultapi.Client, mount string) (bool, error) {
+	mounts, err := vclient.Logical().Read("sys/mounts")
+	if err != nil {
+		return false, fmt.Errorf("error when listing mounts: %v", err)
+	}
+	if mounts == nil {
+		return false, fmt.Errorf("empty response when listing mounts")
+	}
+	entry, ok := mounts.Data[strings.Trim(mount, "/")+"/"].(map[string]interface{})
+	if !ok || entry["type"] != "kv" {
+		return false, nil
+	}
+	options, _ := entry["options"].(map[string]interface{})
+	return options["version"] == "2", nil
+}
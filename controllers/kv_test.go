@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestKVRequiresVersion2(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Name: "secret", Namespace: apiv1.WatchNamespace}
+	kinds := []struct {
+		name       string
+		object     runtime.Object
+		reconciler func(c client.Client) reconcile.Reconciler
+		path       string
+	}{
+		{
+			name: "kvconfig",
+			object: &apiv1.KVConfig{
+				ObjectMeta: objectMeta,
+				Spec:       &apiv1.KVConfigSpec{SecretsEngineRef: "secret", MaxVersions: 5},
+			},
+			reconciler: func(c client.Client) reconcile.Reconciler {
+				return &KVConfigReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			},
+			path: "secret/config",
+		},
+		{
+			name: "kvmetadata",
+			object: &apiv1.KVMetadata{
+				ObjectMeta: objectMeta,
+				Spec:       &apiv1.KVMetadataSpec{SecretsEngineRef: "secret", Path: "app", MaxVersions: 5},
+			},
+			reconciler: func(c client.Client) reconcile.Reconciler {
+				return &KVMetadataReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+			},
+			path: "secret/metadata/app",
+		},
+	}
+	for _, kind := range kinds {
+		for _, version := range []string{"1", "2"} {
+			t.Run(kind.name+" v"+version, func(t *testing.T) {
+				vault := newFakeVault()
+				defer vault.Close()
+				vault.set("sys/mounts", map[string]interface{}{
+					"secret/": map[string]interface{}{
+						"type":    "kv",
+						"options": map[string]interface{}{"version": version},
+					},
+				})
+				c := newFakeClient(t, vault, newCreatedSecretsEngine("secret", "kv"), kind.object.DeepCopyObject())
+				r := kind.reconciler(c)
+				req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "secret", Namespace: apiv1.WatchNamespace}}
+
+				_, err := r.Reconcile(req)
+				_, written := vault.get(kind.path)
+				if version == "1" {
+					if err == nil {
+						t.Error("expected an error for a kv version 1 mount")
+					}
+					if written {
+						t.Errorf("expected %s not to be written", kind.path)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected reconcile error: %v", err)
+				}
+				if !written {
+					t.Errorf("expected %s to be written", kind.path)
+				}
+			})
+		}
+	}
+}
+
+func TestKVPushPathChange(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	vault.respond("secret/data/new", map[string]interface{}{"version": 1})
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: apiv1.WatchNamespace},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	push := &apiv1.KVPush{
+		ObjectMeta: metav1.ObjectMeta{Name: "push", Namespace: apiv1.WatchNamespace},
+		Spec:       &apiv1.KVPushSpec{SecretName: "source", Mount: "secret", Path: "new", DestroyMetadataOnDelete: true},
+		Status: &apiv1.KVPushStatus{
+			State:   apiv1.KVPushCreatedState,
+			Version: 5,
+			Mount:   "secret",
+			Path:    "old",
+		},
+	}
+	c := newFakeClient(t, vault, source, push)
+	r := &KVPushReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "push", Namespace: apiv1.WatchNamespace}}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatal(err)
+	}
+	written, ok := vault.get("secret/data/new")
+	if !ok {
+		t.Fatal("expected the new path to be written")
+	}
+	if cas := written["options"].(map[string]interface{})["cas"]; cas != float64(0) {
+		t.Errorf("expected check-and-set 0 for the new path, got %v", cas)
+	}
+	if !vault.deleted("secret/metadata/old") {
+		t.Error("expected the previous path to be destroyed")
+	}
+	if err := c.Get(context.Background(), req.NamespacedName, push); err != nil {
+		t.Fatal(err)
+	}
+	if push.Status.Path != "new" || push.Status.Version != 1 {
+		t.Errorf("expected version 1 of the new path in status, got %s version %d", push.Status.Path, push.Status.Version)
+	}
+}
+
+func TestKVMetadataPathChange(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	vault.set("sys/mounts", map[string]interface{}{
+		"secret/": map[string]interface{}{
+			"type":    "kv",
+			"options": map[string]interface{}{"version": "2"},
+		},
+	})
+	metadata := &apiv1.KVMetadata{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: apiv1.WatchNamespace},
+		Spec:       &apiv1.KVMetadataSpec{SecretsEngineRef: "secret", Path: "new", MaxVersions: 5},
+		Status: &apiv1.KVMetadataStatus{
+			State:      apiv1.KVMetadataCreatedState,
+			Path:       "secret",
+			SecretPath: "old",
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSecretsEngine("secret", "kv"), metadata)
+	r := &KVMetadataReconciler{Client: c, Log: ctrl.Log, Recorder: record.NewFakeRecorder(10)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "app", Namespace: apiv1.WatchNamespace}}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := vault.get("secret/metadata/new"); !ok || data["max_versions"] != float64(5) {
+		t.Errorf("expected the new path to be written, got %v", data)
+	}
+	if data, ok := vault.get("secret/metadata/old"); !ok || data["max_versions"] != float64(0) {
+		t.Errorf("expected the previous path to be reset, got %v", data)
+	}
+	if err := c.Get(context.Background(), req.NamespacedName, metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Status.SecretPath != "new" {
+		t.Errorf("expected the new path in status, got %s", metadata.Status.SecretPath)
+	}
+}
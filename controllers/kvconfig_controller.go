@@ -0,0 +1,179 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// KVConfigReconciler reconciles a KVConfig object
+type KVConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kvconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kvconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *KVConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("kvconfig", req.NamespacedName)
+
+	kvConfig := &apiv1.KVConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, kvConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for <mount>/config, the config is removed along with the mount
+	if kvConfig.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(kvConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(kvConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(kvConfig)
+	if err != nil {
+		r.Recorder.Event(kvConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking kvconfig IsUptoDate: %v", err)
+	}
+
+	if !kvConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing kv config %v", req.NamespacedName))
+		if err := r.put(kvConfig); err != nil {
+			r.Recorder.Event(kvConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing kvconfig: %v", err)
+		}
+		r.Recorder.Event(kvConfig, corev1.EventTypeNormal, "updated", "kv config is written")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KVConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *KVConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.KVConfig{}).
+		Complete(r)
+}
+
+func (r *KVConfigReconciler) put(k *apiv1.KVConfig) error {
+	path, err := resolveMountPath(r.Client, k.GetNamespace(), k.Spec.SecretsEngineRef, k.Spec.Mount, "kv")
+	if err != nil {
+		return err
+	}
+	v2, err := isKVv2(r.APIClient, path)
+	if err != nil {
+		return err
+	}
+	if !v2 {
+		return fmt.Errorf("%s is not a kv version 2 mount", path)
+	}
+	deleteVersionAfter := k.Spec.DeleteVersionAfter
+	if deleteVersionAfter == "" {
+		deleteVersionAfter = "0s"
+	}
+	data := map[string]interface{}{
+		"max_versions":         k.Spec.MaxVersions,
+		"cas_required":         k.Spec.CASRequired,
+		"delete_version_after": deleteVersionAfter,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/config", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := k.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.KVConfigUpdatedState
+	if !k.IsCreated() {
+		state = apiv1.KVConfigCreatedState
+	}
+	k.Status = &apiv1.KVConfigStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), k)
+}
+
+// IsUptoDate returns true if a kv config is current
+func (r *KVConfigReconciler) IsUptoDate(k *apiv1.KVConfig) (bool, error) {
+	hash, err := k.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating kvconfig hash: %v", err)
+	}
+	if k.Status == nil {
+		return false, nil
+	}
+	if k.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,237 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// KVMetadataReconciler reconciles a KVMetadata object
+type KVMetadataReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kvmetadata,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kvmetadata/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *KVMetadataReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("kvmetadata", req.NamespacedName)
+
+	metadata := &apiv1.KVMetadata{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, metadata)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(metadata, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(metadata, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if metadata.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(metadata)
+		if err != nil {
+			r.Recorder.Event(metadata, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(metadata, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(metadata)
+	if err != nil {
+		r.Recorder.Event(metadata, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking kvmetadata IsUptoDate: %v", err)
+	}
+
+	if !metadata.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing kv metadata %v", metadata.Spec.Path))
+		if err := r.put(metadata); err != nil {
+			r.Recorder.Event(metadata, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing kvmetadata: %v", err)
+		}
+
+		if !metadata.HasFinalizer(apiv1.KVMetadataFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(metadata); err != nil {
+				r.Recorder.Event(metadata, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(metadata, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(metadata, corev1.EventTypeNormal, "updated", "kv metadata is written")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KVMetadataReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *KVMetadataReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.KVMetadata{}).
+		Complete(r)
+}
+
+// delete resets the metadata settings, deleting <mount>/metadata/<path> would destroy every version of the secret
+func (r *KVMetadataReconciler) delete(k *apiv1.KVMetadata) error {
+	r.Log.Info(fmt.Sprintf("resetting kv metadata %s", k.GetName()))
+	if k.Status == nil || k.Status.Path == "" {
+		return nil
+	}
+	secretPath := k.Status.SecretPath
+	if secretPath == "" {
+		// statuses written before the secret path was recorded
+		secretPath = k.Spec.Path
+	}
+	return r.reset(k.Status.Path, secretPath)
+}
+
+// reset writes the default metadata settings to <mount>/metadata/<path>
+func (r *KVMetadataReconciler) reset(mount, path string) error {
+	// on anything but a kv v2 mount the metadata path would be written as a secret
+	v2, err := isKVv2(r.APIClient, mount)
+	if err != nil || !v2 {
+		return err
+	}
+	_, err = r.APIClient.Logical().Write(kvPath(mount, "metadata", path), map[string]interface{}{
+		"max_versions":         0,
+		"cas_required":         false,
+		"delete_version_after": "0s",
+		"custom_metadata":      map[string]string{},
+	})
+	return err
+}
+
+func (r *KVMetadataReconciler) put(k *apiv1.KVMetadata) error {
+	path, err := resolveMountPath(r.Client, k.GetNamespace(), k.Spec.SecretsEngineRef, k.Spec.Mount, "kv")
+	if err != nil {
+		return err
+	}
+	v2, err := isKVv2(r.APIClient, path)
+	if err != nil {
+		return err
+	}
+	if !v2 {
+		return fmt.Errorf("%s is not a kv version 2 mount", path)
+	}
+	deleteVersionAfter := k.Spec.DeleteVersionAfter
+	if deleteVersionAfter == "" {
+		deleteVersionAfter = "0s"
+	}
+	customMetadata := k.Spec.CustomMetadata
+	if customMetadata == nil {
+		customMetadata = map[string]string{}
+	}
+	// only the metadata endpoint is written, the secret data is never read or changed
+	_, err = r.APIClient.Logical().Write(kvPath(path, "metadata", k.Spec.Path), map[string]interface{}{
+		"max_versions":         k.Spec.MaxVersions,
+		"cas_required":         k.Spec.CASRequired,
+		"delete_version_after": deleteVersionAfter,
+		"custom_metadata":      customMetadata,
+	})
+	if err != nil {
+		return err
+	}
+	if k.Status != nil && k.Status.SecretPath != "" && (k.Status.Path != path || k.Status.SecretPath != k.Spec.Path) {
+		// the settings no longer apply to the previous secret
+		r.Log.Info(fmt.Sprintf("resetting previous kv metadata %s/%s", k.Status.Path, k.Status.SecretPath))
+		if err := r.reset(k.Status.Path, k.Status.SecretPath); err != nil {
+			return fmt.Errorf("error when resetting previous kv metadata: %v", err)
+		}
+	}
+	hash, err := k.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.KVMetadataUpdatedState
+	if !k.IsCreated() {
+		state = apiv1.KVMetadataCreatedState
+	}
+	k.Status = &apiv1.KVMetadataStatus{
+		Hash:       hash,
+		State:      state,
+		Path:       path,
+		SecretPath: k.Spec.Path,
+	}
+	return r.Update(context.Background(), k)
+}
+
+// IsUptoDate returns true if a kv metadata is current
+func (r *KVMetadataReconciler) IsUptoDate(k *apiv1.KVMetadata) (bool, error) {
+	hash, err := k.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating kvmetadata hash: %v", err)
+	}
+	if k.Status == nil {
+		return false, nil
+	}
+	if k.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
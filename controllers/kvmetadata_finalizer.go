@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *KVMetadataReconciler) addFinalizer(instance *apiv1.KVMetadata) error {
+	instance.AddFinalizer(apiv1.KVMetadataFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *KVMetadataReconciler) handleFinalizer(k *apiv1.KVMetadata) error {
+	if !k.HasFinalizer(apiv1.KVMetadataFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(k); err != nil {
+		return err
+	}
+	k.RemoveFinalizer(apiv1.KVMetadataFinalizer)
+	return r.Update(context.Background(), k)
+}
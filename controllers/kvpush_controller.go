@@ -0,0 +1,317 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/mitchellh/hashstructure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// KVPushReconciler reconciles a KVPush object
+type KVPushReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kvpushes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=kvpushes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *KVPushReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("kvpush", req.NamespacedName)
+
+	push := &apiv1.KVPush{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, push)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if push.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(push)
+		if err != nil {
+			r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(push, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	data, err := r.getSourceData(push)
+	if err != nil {
+		r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to read source secret: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(push, data)
+	if err != nil {
+		r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking kvpush IsUptoDate: %v", err)
+	}
+
+	if !push.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing kv secret %v/%v", push.Spec.Mount, push.Spec.Path))
+		if err := r.put(push, data); err != nil {
+			r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write kv secret: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing kvpush: %v", err)
+		}
+		if push.Status.State == apiv1.KVPushConflictState {
+			r.Recorder.Event(push, corev1.EventTypeWarning, "conflict", push.Status.Conflict)
+			return ctrl.Result{}, nil
+		}
+
+		if !push.HasFinalizer(apiv1.KVPushFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(push); err != nil {
+				r.Recorder.Event(push, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(push, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(push, corev1.EventTypeNormal, "updated", fmt.Sprintf("kv secret version %d is written", push.Status.Version))
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KVPushReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *KVPushReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.KVPush{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretToPushes),
+		}).
+		Complete(r)
+}
+
+// secretToPushes enqueues the pushes reading a Secret
+func (r *KVPushReconciler) secretToPushes(o handler.MapObject) []reconcile.Request {
+	pushes := &apiv1.KVPushList{}
+	if err := r.List(context.Background(), pushes, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list kv pushes")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, push := range pushes.Items {
+		if push.Spec != nil && push.Spec.SecretName == o.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: push.GetName(), Namespace: push.GetNamespace()},
+			})
+		}
+	}
+	return requests
+}
+
+// getSourceData returns the selected keys of the source Secret
+func (r *KVPushReconciler) getSourceData(k *apiv1.KVPush) (map[string]interface{}, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: k.Spec.SecretName, Namespace: k.GetNamespace()}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("error when getting secret %s: %v", k.Spec.SecretName, err)
+	}
+	data := map[string]interface{}{}
+	if len(k.Spec.Keys) == 0 {
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		return data, nil
+	}
+	for _, key := range k.Spec.Keys {
+		value, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s", key, k.Spec.SecretName)
+		}
+		data[key] = string(value)
+	}
+	return data, nil
+}
+
+func (r *KVPushReconciler) delete(k *apiv1.KVPush) error {
+	if k.Status == nil || !k.Spec.DestroyMetadataOnDelete {
+		return nil
+	}
+	mount, path := writtenKVPath(k)
+	r.Log.Info(fmt.Sprintf("destroying kv secret %s/%s", mount, path))
+	_, err := r.APIClient.Logical().Delete(kvPath(mount, "metadata", path))
+	return err
+}
+
+// writtenKVPath returns the mount and path last written by the controller, statuses written
+// before they were recorded fall back to the spec
+func writtenKVPath(k *apiv1.KVPush) (string, string) {
+	if k.Status == nil || k.Status.Mount == "" {
+		return k.Spec.Mount, k.Spec.Path
+	}
+	return k.Status.Mount, k.Status.Path
+}
+
+func (r *KVPushReconciler) put(k *apiv1.KVPush, data map[string]interface{}) error {
+	hash, err := k.GetHash()
+	if err != nil {
+		return err
+	}
+	dataHash, err := hashstructure.Hash(data, nil)
+	if err != nil {
+		return err
+	}
+	// the version of a previous mount or path does not apply to the current one
+	oldMount, oldPath := writtenKVPath(k)
+	moved := oldMount != k.Spec.Mount || oldPath != k.Spec.Path
+	var cas int64
+	if k.Status != nil && !moved {
+		cas = k.Status.Version
+	}
+
+	status := &apiv1.KVPushStatus{
+		Hash:     hash,
+		State:    apiv1.KVPushUpdatedState,
+		DataHash: fmt.Sprintf("%d", dataHash),
+		Mount:    k.Spec.Mount,
+		Path:     k.Spec.Path,
+	}
+	if !k.IsCreated() {
+		status.State = apiv1.KVPushCreatedState
+	}
+	version, err := writeKV(r.APIClient, k.Spec.Mount, k.Spec.Path, data, cas)
+	if err != nil && !isCASConflict(err) {
+		return err
+	}
+	if err != nil {
+		current, err := kvCurrentVersion(r.APIClient, k.Spec.Mount, k.Spec.Path)
+		if err != nil {
+			return err
+		}
+		existing, _, err := readKV(r.APIClient, k.Spec.Mount, k.Spec.Path, 2, int(current))
+		switch {
+		case err == nil && reflect.DeepEqual(existing, data):
+			// another writer already wrote the same data, the version is adopted
+			version = current
+		case k.Spec.Overwrite:
+			version, err = writeKV(r.APIClient, k.Spec.Mount, k.Spec.Path, data, current)
+			if err != nil {
+				return err
+			}
+		default:
+			status = &apiv1.KVPushStatus{
+				State:           apiv1.KVPushConflictState,
+				Version:         cas,
+				Conflict:        fmt.Sprintf("check-and-set expected version %d but version %d was written by another writer", cas, current),
+				ConflictVersion: current,
+			}
+			if k.Status != nil {
+				// the previous write stays the one tracked until the new path is written
+				status.Hash = k.Status.Hash
+				status.DataHash = k.Status.DataHash
+				status.Version = k.Status.Version
+				status.Mount = k.Status.Mount
+				status.Path = k.Status.Path
+			}
+		}
+	}
+	if status.State != apiv1.KVPushConflictState {
+		status.Version = version
+		if k.Status != nil && moved && k.Spec.DestroyMetadataOnDelete {
+			r.Log.Info(fmt.Sprintf("destroying previous kv secret %s/%s", oldMount, oldPath))
+			if _, err := r.APIClient.Logical().Delete(kvPath(oldMount, "metadata", oldPath)); err != nil {
+				return fmt.Errorf("error when destroying previous kv secret %s/%s: %v", oldMount, oldPath, err)
+			}
+		}
+	}
+	if reflect.DeepEqual(k.Status, status) {
+		// the conflict is unchanged, updating would only trigger another reconcile
+		return nil
+	}
+	k.Status = status
+	return r.Update(context.Background(), k)
+}
+
+// IsUptoDate returns true if the source data has been written to vault
+func (r *KVPushReconciler) IsUptoDate(k *apiv1.KVPush, data map[string]interface{}) (bool, error) {
+	hash, err := k.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating kvpush hash: %v", err)
+	}
+	if k.Status == nil {
+		return false, nil
+	}
+	dataHash, err := hashstructure.Hash(data, nil)
+	if err != nil {
+		return false, fmt.Errorf("error when calculating kvpush data hash: %v", err)
+	}
+	if k.Status.Hash != hash || k.Status.DataHash != fmt.Sprintf("%d", dataHash) || k.Status.State == apiv1.KVPushConflictState {
+		return false, nil
+	}
+	return true, nil
+}
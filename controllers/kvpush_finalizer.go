@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *KVPushReconciler) addFinalizer(instance *apiv1.KVPush) error {
+	instance.AddFinalizer(apiv1.KVPushFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *KVPushReconciler) handleFinalizer(k *apiv1.KVPush) error {
+	if !k.HasFinalizer(apiv1.KVPushFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(k); err != nil {
+		return err
+	}
+	k.RemoveFinalizer(apiv1.KVPushFinalizer)
+	return r.Update(context.Background(), k)
+}
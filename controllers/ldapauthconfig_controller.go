@@ -0,0 +1,198 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// LDAPAuthConfigReconciler reconciles a LDAPAuthConfig object
+type LDAPAuthConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=ldapauthconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=ldapauthconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *LDAPAuthConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("ldapauthconfig", req.NamespacedName)
+
+	authConfig := &apiv1.LDAPAuthConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, authConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Vault has no delete for auth/<path>/config, the config is removed along with the mount
+	if authConfig.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	bindPass, bindPassVersion, err := r.getBindPass(authConfig)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get bind password: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting bind password: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(authConfig, bindPassVersion)
+	if err != nil {
+		r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking ldapauthconfig IsUptoDate: %v", err)
+	}
+
+	if !authConfig.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing ldap auth config for sysauth %v", authConfig.Spec.SysAuthRef))
+		if err := r.put(authConfig, bindPass, bindPassVersion); err != nil {
+			r.Recorder.Event(authConfig, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing ldapauthconfig: %v", err)
+		}
+		r.Recorder.Event(authConfig, corev1.EventTypeNormal, "updated", "ldap auth config is written")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *LDAPAuthConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *LDAPAuthConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.LDAPAuthConfig{}).
+		Complete(r)
+}
+
+func (r *LDAPAuthConfigReconciler) getBindPass(l *apiv1.LDAPAuthConfig) (string, string, error) {
+	if l.Spec.BindPass == nil {
+		return "", "", nil
+	}
+	return getSecretValue(r.Client, l.GetNamespace(), l.Spec.BindPass)
+}
+
+func (r *LDAPAuthConfigReconciler) put(l *apiv1.LDAPAuthConfig, bindPass, bindPassVersion string) error {
+	path, err := getAuthMountPath(r.Client, l.GetNamespace(), l.Spec.SysAuthRef, "ldap")
+	if err != nil {
+		return err
+	}
+	if err := validateAuthMountType(r.APIClient, path, "ldap"); err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"url":          l.Spec.URL,
+		"binddn":       l.Spec.BindDN,
+		"userdn":       l.Spec.UserDN,
+		"userattr":     l.Spec.UserAttr,
+		"userfilter":   l.Spec.UserFilter,
+		"upndomain":    l.Spec.UPNDomain,
+		"groupdn":      l.Spec.GroupDN,
+		"groupfilter":  l.Spec.GroupFilter,
+		"groupattr":    l.Spec.GroupAttr,
+		"certificate":  l.Spec.Certificate,
+		"insecure_tls": l.Spec.InsecureTLS,
+		"starttls":     l.Spec.StartTLS,
+	}
+	if bindPass != "" {
+		data["bindpass"] = bindPass
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/config", path), data)
+	if err != nil {
+		return err
+	}
+	hash, err := l.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.LDAPAuthConfigUpdatedState
+	if !l.IsCreated() {
+		state = apiv1.LDAPAuthConfigCreatedState
+	}
+	l.Status = &apiv1.LDAPAuthConfigStatus{
+		Hash:            hash,
+		State:           state,
+		BindPassVersion: bindPassVersion,
+	}
+	return r.Update(context.Background(), l)
+}
+
+// IsUptoDate returns true if a ldap auth config is current
+func (r *LDAPAuthConfigReconciler) IsUptoDate(l *apiv1.LDAPAuthConfig, bindPassVersion string) (bool, error) {
+	hash, err := l.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating ldapauthconfig hash: %v", err)
+	}
+	if l.Status == nil {
+		return false, nil
+	}
+	if l.Status.Hash != hash || l.Status.BindPassVersion != bindPassVersion {
+		return false, nil
+	}
+	return true, nil
+}
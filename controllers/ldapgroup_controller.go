@@ -0,0 +1,197 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// LDAPGroupReconciler reconciles a LDAPGroup object
+type LDAPGroupReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=ldapgroups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=ldapgroups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *LDAPGroupReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("ldapgroup", req.NamespacedName)
+
+	group := &apiv1.LDAPGroup{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, group)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if group.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(group)
+		if err != nil {
+			r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(group, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(group)
+	if err != nil {
+		r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking ldapgroup IsUptoDate: %v", err)
+	}
+
+	if !group.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating ldap group %v", group.Spec.Name))
+		if err := r.put(group); err != nil {
+			if !group.IsCreated() {
+				r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating ldapgroup: %v", err)
+		}
+
+		if !group.HasFinalizer(apiv1.LDAPGroupFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(group); err != nil {
+				r.Recorder.Event(group, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(group, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(group, corev1.EventTypeNormal, "updated", "ldap group is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *LDAPGroupReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *LDAPGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.LDAPGroup{}).
+		Complete(r)
+}
+
+func (r *LDAPGroupReconciler) delete(l *apiv1.LDAPGroup) error {
+	r.Log.Info(fmt.Sprintf("deleting ldap group %s", l.GetName()))
+	if l.Status == nil || l.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/groups/%s", l.Status.Path, l.Spec.Name))
+	return err
+}
+
+func (r *LDAPGroupReconciler) put(l *apiv1.LDAPGroup) error {
+	path, err := getAuthMountPath(r.Client, l.GetNamespace(), l.Spec.SysAuthRef, "ldap")
+	if err != nil {
+		return err
+	}
+	if err := validateAuthMountType(r.APIClient, path, "ldap"); err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"policies": l.Spec.Policies,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/groups/%s", path, l.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := l.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.LDAPGroupUpdatedState
+	if !l.IsCreated() {
+		state = apiv1.LDAPGroupCreatedState
+	}
+	l.Status = &apiv1.LDAPGroupStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), l)
+}
+
+// IsUptoDate returns true if a ldap group is current
+func (r *LDAPGroupReconciler) IsUptoDate(l *apiv1.LDAPGroup) (bool, error) {
+	hash, err := l.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating ldapgroup hash: %v", err)
+	}
+	if l.Status == nil {
+		return false, nil
+	}
+	if l.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *LDAPGroupReconciler) addFinalizer(instance *apiv1.LDAPGroup) error {
+	instance.AddFinalizer(apiv1.LDAPGroupFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *LDAPGroupReconciler) handleFinalizer(l *apiv1.LDAPGroup) error {
+	if !l.HasFinalizer(apiv1.LDAPGroupFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(l); err != nil {
+		return err
+	}
+	l.RemoveFinalizer(apiv1.LDAPGroupFinalizer)
+	return r.Update(context.Background(), l)
+}
@@ -0,0 +1,198 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// LDAPUserReconciler reconciles a LDAPUser object
+type LDAPUserReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=ldapusers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=ldapusers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *LDAPUserReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("ldapuser", req.NamespacedName)
+
+	user := &apiv1.LDAPUser{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, user)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if user.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(user)
+		if err != nil {
+			r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(user, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(user)
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking ldapuser IsUptoDate: %v", err)
+	}
+
+	if !user.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating ldap user %v", user.Spec.Name))
+		if err := r.put(user); err != nil {
+			if !user.IsCreated() {
+				r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating ldapuser: %v", err)
+		}
+
+		if !user.HasFinalizer(apiv1.LDAPUserFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(user); err != nil {
+				r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(user, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(user, corev1.EventTypeNormal, "updated", "ldap user is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *LDAPUserReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *LDAPUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.LDAPUser{}).
+		Complete(r)
+}
+
+func (r *LDAPUserReconciler) delete(l *apiv1.LDAPUser) error {
+	r.Log.Info(fmt.Sprintf("deleting ldap user %s", l.GetName()))
+	if l.Status == nil || l.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/users/%s", l.Status.Path, l.Spec.Name))
+	return err
+}
+
+func (r *LDAPUserReconciler) put(l *apiv1.LDAPUser) error {
+	path, err := getAuthMountPath(r.Client, l.GetNamespace(), l.Spec.SysAuthRef, "ldap")
+	if err != nil {
+		return err
+	}
+	if err := validateAuthMountType(r.APIClient, path, "ldap"); err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"policies": l.Spec.Policies,
+		"groups":   l.Spec.Groups,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/users/%s", path, l.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := l.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.LDAPUserUpdatedState
+	if !l.IsCreated() {
+		state = apiv1.LDAPUserCreatedState
+	}
+	l.Status = &apiv1.LDAPUserStatus{
+		Hash:  hash,
+		State: state,
+		Path:  path,
+	}
+	return r.Update(context.Background(), l)
+}
+
+// IsUptoDate returns true if a ldap user is current
+func (r *LDAPUserReconciler) IsUptoDate(l *apiv1.LDAPUser) (bool, error) {
+	hash, err := l.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating ldapuser hash: %v", err)
+	}
+	if l.Status == nil {
+		return false, nil
+	}
+	if l.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
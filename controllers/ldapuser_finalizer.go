@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *LDAPUserReconciler) addFinalizer(instance *apiv1.LDAPUser) error {
+	instance.AddFinalizer(apiv1.LDAPUserFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *LDAPUserReconciler) handleFinalizer(l *apiv1.LDAPUser) error {
+	if !l.HasFinalizer(apiv1.LDAPUserFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(l); err != nil {
+		return err
+	}
+	l.RemoveFinalizer(apiv1.LDAPUserFinalizer)
+	return r.Update(context.Background(), l)
+}
@@ -17,9 +17,15 @@ var databricksRequestHistogram = prometheus.NewHistogramVec(prometheus.Histogram
 	Help: "Duration of upstream calls to Databricks REST service endpoints",
 }, []string{"object_type", "action", "outcome"})
 
+var certificateExpiryGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "vault_certificate_expiry_timestamp_seconds",
+	Help: "Unix time the certificate of a VaultCertificate expires at",
+}, []string{"namespace", "name"})
+
 func init() {
 	// Register custom metrics with the global prometheus registry
 	metrics.Registry.MustRegister(databricksRequestHistogram)
+	metrics.Registry.MustRegister(certificateExpiryGauge)
 }
 
 // NewExecution creates an Execution instance and starts the timer
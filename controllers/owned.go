@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ownedObject is a kubernetes object written on behalf of a custom resource
+type ownedObject interface {
+	metav1.Object
+	runtime.Object
+}
+
+// writeOwned creates or updates obj, identified by its name and namespace, as controlled by owner.
+// mutate sets the desired content once the current object is read. An existing object which is not
+// controlled by owner is never adopted nor changed, an error is returned instead
+func writeOwned(c client.Client, scheme *runtime.Scheme, owner metav1.Object, obj ownedObject, mutate func()) error {
+	name := obj.GetName()
+	err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: obj.GetNamespace()}, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if exists && !metav1.IsControlledBy(obj, owner) {
+		return fmt.Errorf("%s already exists and is not owned by %s, refusing to overwrite it", name, owner.GetName())
+	}
+	mutate()
+	if err := controllerutil.SetControllerReference(owner, obj, scheme); err != nil {
+		return err
+	}
+	if exists {
+		return c.Update(context.Background(), obj)
+	}
+	return c.Create(context.Background(), obj)
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestWriteOwned(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	scheme := newScheme(t)
+	owner := &apiv1.VaultSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: apiv1.WatchNamespace, UID: "owner"},
+	}
+	unowned := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "unowned", Namespace: apiv1.WatchNamespace},
+		Data:       map[string][]byte{"password": []byte("keep")},
+	}
+	c := newFakeClient(t, vault, owner, unowned)
+
+	write := func(name, value string) error {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: apiv1.WatchNamespace}}
+		return writeOwned(c, scheme, owner, secret, func() {
+			secret.Data = map[string][]byte{"password": []byte(value)}
+		})
+	}
+	read := func(name string) *corev1.Secret {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: apiv1.WatchNamespace}, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	if err := write("unowned", "overwritten"); err == nil {
+		t.Error("expected an error writing a secret owned by nobody")
+	}
+	if secret := read("unowned"); string(secret.Data["password"]) != "keep" || len(secret.GetOwnerReferences()) != 0 {
+		t.Errorf("expected the unowned secret to be untouched, got %v", secret)
+	}
+
+	if err := write("owned", "first"); err != nil {
+		t.Fatalf("unexpected error creating the secret: %v", err)
+	}
+	if secret := read("owned"); !metav1.IsControlledBy(secret, owner) {
+		t.Errorf("expected the secret to be controlled by its owner, got %v", secret.GetOwnerReferences())
+	}
+	if err := write("owned", "second"); err != nil {
+		t.Fatalf("unexpected error updating the secret: %v", err)
+	}
+	if secret := read("owned"); string(secret.Data["password"]) != "second" {
+		t.Errorf("expected the secret to be updated, got %s", secret.Data["password"])
+	}
+}
@@ -0,0 +1,414 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// PKIIssuerReconciler reconciles a PKIIssuer object
+type PKIIssuerReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=pkiissuers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=pkiissuers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *PKIIssuerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("pkiissuer", req.NamespacedName)
+
+	issuer := &apiv1.PKIIssuer{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, issuer)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// the CA is kept in vault, the ConfigMap is garbage collected along with the object
+	if issuer.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := issuer.Spec.Validate(); err != nil {
+		r.Recorder.Event(issuer, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(issuer, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(issuer, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	parent, err := r.getParent(issuer)
+	if err != nil {
+		r.Recorder.Event(issuer, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get parent issuer: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting parent pkiissuer: %v", err)
+	}
+	if issuer.Spec.Type == apiv1.PKIIssuerIntermediateType && parent == nil {
+		// the parent watch requeues the issuer once its chain is published
+		if issuer.Status == nil || issuer.Status.State != apiv1.PKIIssuerPendingState {
+			r.Recorder.Event(issuer, corev1.EventTypeNormal, "pending", fmt.Sprintf("waiting for parent issuer %s", issuer.Spec.ParentRef))
+			if issuer.Status == nil {
+				issuer.Status = &apiv1.PKIIssuerStatus{}
+			}
+			issuer.Status.State = apiv1.PKIIssuerPendingState
+			if err := r.Update(ctx, issuer); err != nil {
+				return ctrl.Result{}, fmt.Errorf("error when updating pkiissuer status: %v", err)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(issuer, parent)
+	if err != nil {
+		r.Recorder.Event(issuer, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking pkiissuer IsUptoDate: %v", err)
+	}
+
+	if !issuer.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating pki issuer %v", issuer.Spec.CommonName))
+		if err := r.put(issuer, parent); err != nil {
+			r.Recorder.Event(issuer, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating pkiissuer: %v", err)
+		}
+		if issuer.Status.State != apiv1.PKIIssuerOutdatedState {
+			r.Recorder.Event(issuer, corev1.EventTypeNormal, "updated", fmt.Sprintf("ca chain is published to configmap %s", issuer.Status.ConfigMapName))
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *PKIIssuerReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *PKIIssuerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.PKIIssuer{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&source.Kind{Type: &apiv1.PKIIssuer{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.parentToIssuers),
+		}).
+		Complete(r)
+}
+
+// parentToIssuers enqueues the intermediates signed by an issuer so they pick up its chain
+func (r *PKIIssuerReconciler) parentToIssuers(o handler.MapObject) []reconcile.Request {
+	issuers := &apiv1.PKIIssuerList{}
+	if err := r.List(context.Background(), issuers, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list pki issuers")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, issuer := range issuers.Items {
+		if issuer.Spec != nil && issuer.Spec.ParentRef == o.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: issuer.GetName(), Namespace: issuer.GetNamespace()},
+			})
+		}
+	}
+	return requests
+}
+
+// getParent returns the parent of an intermediate once its chain is published, or nil while it is not
+func (r *PKIIssuerReconciler) getParent(p *apiv1.PKIIssuer) (*apiv1.PKIIssuer, error) {
+	if p.Spec.Type != apiv1.PKIIssuerIntermediateType {
+		return nil, nil
+	}
+	if p.Spec.ParentRef == p.GetName() {
+		return nil, fmt.Errorf("issuer cannot be its own parent")
+	}
+	parent := &apiv1.PKIIssuer{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: p.Spec.ParentRef, Namespace: p.GetNamespace()}, parent)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if parent.Status == nil || parent.Status.SerialNumber == "" || parent.Status.ConfigMapName == "" {
+		return nil, nil
+	}
+	return parent, nil
+}
+
+func (r *PKIIssuerReconciler) put(p *apiv1.PKIIssuer, parent *apiv1.PKIIssuer) error {
+	path, err := resolveMountPath(r.Client, p.GetNamespace(), p.Spec.SecretsEngineRef, p.Spec.Mount, "pki")
+	if err != nil {
+		return err
+	}
+	if parent != nil && parent.Status.Path == path {
+		return fmt.Errorf("intermediate issuer cannot use the pki mount %s of its parent %s", path, parent.GetName())
+	}
+	issueHash, err := p.GetIssueHash()
+	if err != nil {
+		return err
+	}
+
+	var parentChain, parentChainVersion, parentSerial string
+	if parent != nil {
+		parentSerial = parent.Status.SerialNumber
+		parentChain, parentChainVersion, err = getConfigMapValue(r.Client, parent.GetNamespace(), parent.Status.ConfigMapName, apiv1.PKIIssuerChainKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	certificate, err := r.readCA(path)
+	if err != nil {
+		return err
+	}
+	var outdated []string
+	parentReissued := false
+	if certificate != "" && p.Status != nil {
+		// the CA is only generated once, changes to it have to be re-issued by hand
+		if p.Status.IssueHash != "" && p.Status.IssueHash != issueHash {
+			outdated = append(outdated, "the ca settings changed")
+			issueHash = p.Status.IssueHash
+		}
+		if p.Status.ParentSerialNumber != "" && p.Status.ParentSerialNumber != parentSerial {
+			outdated = append(outdated, fmt.Sprintf("parent issuer %s was re-issued", p.Spec.ParentRef))
+			parentSerial = p.Status.ParentSerialNumber
+			parentReissued = true
+		}
+	}
+	if certificate == "" {
+		switch p.Spec.Type {
+		case apiv1.PKIIssuerRootType:
+			certificate, err = r.generateRoot(p, path)
+		case apiv1.PKIIssuerIntermediateType:
+			certificate, err = r.generateIntermediate(p, path, parent.Status.Path, parentChain)
+		}
+		if err != nil {
+			return err
+		}
+		r.Recorder.Event(p, corev1.EventTypeNormal, "created", fmt.Sprintf("%s ca is generated in %s", p.Spec.Type, path))
+	}
+
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/config/urls", path), map[string]interface{}{
+		"issuing_certificates":    p.Spec.IssuingCertificates,
+		"crl_distribution_points": p.Spec.CRLDistributionPoints,
+		"ocsp_servers":            p.Spec.OCSPServers,
+	})
+	if err != nil {
+		return fmt.Errorf("error when writing pki urls: %v", err)
+	}
+
+	if len(outdated) > 0 {
+		r.Recorder.Event(p, corev1.EventTypeWarning, "outdated", fmt.Sprintf("%s, the ca of %s has to be re-issued", strings.Join(outdated, " and "), path))
+	}
+	// the certificate is no longer signed by the parent chain once the parent is re-issued, the published chain is kept
+	if !parentReissued {
+		chain := []string{strings.TrimSpace(certificate)}
+		if parentChain != "" {
+			chain = append(chain, strings.TrimSpace(parentChain))
+		}
+		err = publishConfigMap(r.Client, r.Scheme, p, p.GetConfigMapName(), map[string]string{
+			apiv1.PKIIssuerChainKey: strings.Join(chain, "\n") + "\n",
+		})
+		if err != nil {
+			return fmt.Errorf("error when publishing ca chain: %v", err)
+		}
+	}
+
+	parsed, err := parseCertificate(certificate)
+	if err != nil {
+		return err
+	}
+	hash, err := p.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.PKIIssuerCreatedState
+	if p.IsCreated() && p.Status.SerialNumber != "" {
+		state = apiv1.PKIIssuerUpdatedState
+	}
+	if len(outdated) > 0 {
+		state = apiv1.PKIIssuerOutdatedState
+	}
+	notAfter := metav1.NewTime(parsed.NotAfter)
+	p.Status = &apiv1.PKIIssuerStatus{
+		Hash:               hash,
+		State:              state,
+		Path:               path,
+		SerialNumber:       certificateSerial(parsed),
+		NotAfter:           &notAfter,
+		ConfigMapName:      p.GetConfigMapName(),
+		ParentChainVersion: parentChainVersion,
+		IssueHash:          issueHash,
+		ParentSerialNumber: parentSerial,
+	}
+	return r.Update(context.Background(), p)
+}
+
+// readCA returns the CA certificate of a pki mount, empty if none is configured yet
+func (r *PKIIssuerReconciler) readCA(path string) (string, error) {
+	secret, err := r.APIClient.Logical().Read(fmt.Sprintf("%s/cert/ca", path))
+	if err != nil {
+		return "", fmt.Errorf("error when reading ca of %s: %v", path, err)
+	}
+	if secret == nil {
+		return "", nil
+	}
+	certificate, _ := secret.Data["certificate"].(string)
+	return certificate, nil
+}
+
+func (r *PKIIssuerReconciler) generateData(p *apiv1.PKIIssuer) map[string]interface{} {
+	data := map[string]interface{}{
+		"common_name": p.Spec.CommonName,
+	}
+	if len(p.Spec.AltNames) > 0 {
+		data["alt_names"] = strings.Join(p.Spec.AltNames, ",")
+	}
+	if p.Spec.TTL != "" {
+		data["ttl"] = p.Spec.TTL
+	}
+	if p.Spec.KeyType != "" {
+		data["key_type"] = p.Spec.KeyType
+	}
+	if p.Spec.KeyBits != 0 {
+		data["key_bits"] = p.Spec.KeyBits
+	}
+	return data
+}
+
+func (r *PKIIssuerReconciler) generateRoot(p *apiv1.PKIIssuer, path string) (string, error) {
+	secret, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/root/generate/internal", path), r.generateData(p))
+	if err != nil {
+		return "", fmt.Errorf("error when generating root: %v", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response when generating root")
+	}
+	certificate, _ := secret.Data["certificate"].(string)
+	return certificate, nil
+}
+
+// generateIntermediate creates a CSR in the mount, signs it with the parent mount and sets the signed certificate
+func (r *PKIIssuerReconciler) generateIntermediate(p *apiv1.PKIIssuer, path, parentPath, parentChain string) (string, error) {
+	secret, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/intermediate/generate/internal", path), r.generateData(p))
+	if err != nil {
+		return "", fmt.Errorf("error when generating intermediate csr: %v", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("empty response when generating intermediate csr")
+	}
+	csr, _ := secret.Data["csr"].(string)
+
+	data := r.generateData(p)
+	data["csr"] = csr
+	delete(data, "key_type")
+	delete(data, "key_bits")
+	signed, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/root/sign-intermediate", parentPath), data)
+	if err != nil {
+		return "", fmt.Errorf("error when signing intermediate with %s: %v", parentPath, err)
+	}
+	if signed == nil {
+		return "", fmt.Errorf("empty response when signing intermediate")
+	}
+	certificate, _ := signed.Data["certificate"].(string)
+
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/intermediate/set-signed", path), map[string]interface{}{
+		"certificate": strings.TrimSpace(certificate) + "\n" + strings.TrimSpace(parentChain),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error when setting signed intermediate: %v", err)
+	}
+	return certificate, nil
+}
+
+// IsUptoDate returns true if the spec and the parent chain are unchanged since the chain was published
+func (r *PKIIssuerReconciler) IsUptoDate(p *apiv1.PKIIssuer, parent *apiv1.PKIIssuer) (bool, error) {
+	hash, err := p.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating pkiissuer hash: %v", err)
+	}
+	if p.Status == nil || p.Status.Hash != hash {
+		return false, nil
+	}
+	if parent != nil {
+		_, version, err := getConfigMapValue(r.Client, parent.GetNamespace(), parent.Status.ConfigMapName, apiv1.PKIIssuerChainKey)
+		if err != nil {
+			return false, err
+		}
+		if version != p.Status.ParentChainVersion {
+			return false, nil
+		}
+	}
+	configMap := &corev1.ConfigMap{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: p.Status.ConfigMapName, Namespace: p.GetNamespace()}, configMap)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// newCACertificate returns a PEM encoded self signed CA certificate
+func newCACertificate(t *testing.T, commonName string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestPKIIssuerSameMountAsParent(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	parent := &apiv1.PKIIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "root", Namespace: apiv1.WatchNamespace},
+		Spec:       &apiv1.PKIIssuerSpec{SecretsEngineRef: "pki", Type: apiv1.PKIIssuerRootType, CommonName: "root"},
+		Status: &apiv1.PKIIssuerStatus{
+			State:         apiv1.PKIIssuerCreatedState,
+			Path:          "pki",
+			SerialNumber:  "01",
+			ConfigMapName: "root",
+		},
+	}
+	intermediate := &apiv1.PKIIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "intermediate", Namespace: apiv1.WatchNamespace},
+		Spec: &apiv1.PKIIssuerSpec{
+			SecretsEngineRef: "pki",
+			Type:             apiv1.PKIIssuerIntermediateType,
+			ParentRef:        "root",
+			CommonName:       "intermediate",
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSecretsEngine("pki", "pki"), parent, intermediate)
+	r := &PKIIssuerReconciler{Client: c, Log: ctrl.Log, Scheme: newScheme(t), Recorder: record.NewFakeRecorder(10)}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "intermediate", Namespace: apiv1.WatchNamespace}}
+	if _, err := r.Reconcile(req); err == nil {
+		t.Fatal("expected an error for an intermediate sharing the mount of its parent")
+	}
+	if _, ok := vault.get("pki/config/urls"); ok {
+		t.Error("expected nothing to be written")
+	}
+}
+
+func TestPKIIssuerOutdated(t *testing.T) {
+	vault := newFakeVault()
+	defer vault.Close()
+	vault.set("pki/cert/ca", map[string]interface{}{"certificate": newCACertificate(t, "root")})
+	issuer := &apiv1.PKIIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "root", Namespace: apiv1.WatchNamespace, UID: "root"},
+		Spec: &apiv1.PKIIssuerSpec{
+			SecretsEngineRef: "pki",
+			Type:             apiv1.PKIIssuerRootType,
+			CommonName:       "root",
+			TTL:              "87600h",
+		},
+		Status: &apiv1.PKIIssuerStatus{
+			Hash:          "previous",
+			State:         apiv1.PKIIssuerCreatedState,
+			Path:          "pki",
+			SerialNumber:  "01",
+			ConfigMapName: "root",
+			IssueHash:     "previous",
+		},
+	}
+	c := newFakeClient(t, vault, newCreatedSecretsEngine("pki", "pki"), issuer)
+	recorder := record.NewFakeRecorder(10)
+	r := &PKIIssuerReconciler{Client: c, Log: ctrl.Log, Scheme: newScheme(t), Recorder: recorder}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "root", Namespace: apiv1.WatchNamespace}}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if err := c.Get(context.TODO(), req.NamespacedName, issuer); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.Status.State != apiv1.PKIIssuerOutdatedState {
+		t.Errorf("expected state %s, got %s", apiv1.PKIIssuerOutdatedState, issuer.Status.State)
+	}
+	if issuer.Status.IssueHash != "previous" {
+		t.Errorf("expected the issue hash of the existing ca to be kept, got %s", issuer.Status.IssueHash)
+	}
+	close(recorder.Events)
+	found := false
+	for event := range recorder.Events {
+		if strings.HasPrefix(event, corev1.EventTypeWarning+" outdated") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected an outdated warning event")
+	}
+}
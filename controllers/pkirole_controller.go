@@ -0,0 +1,256 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// PKIRoleReconciler reconciles a PKIRole object
+type PKIRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=pkiroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=pkiroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *PKIRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("pkirole", req.NamespacedName)
+
+	role := &apiv1.PKIRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking pkirole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating pki role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating pkirole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.PKIRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "pki role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *PKIRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *PKIRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.PKIRole{}).
+		Complete(r)
+}
+
+func (r *PKIRoleReconciler) delete(p *apiv1.PKIRole) error {
+	r.Log.Info(fmt.Sprintf("deleting pki role %s", p.GetName()))
+	if p.Status == nil || p.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("%s/roles/%s", p.Status.Path, p.Spec.Name))
+	return err
+}
+
+func (r *PKIRoleReconciler) put(p *apiv1.PKIRole) error {
+	path, err := resolveMountPath(r.Client, p.GetNamespace(), p.Spec.SecretsEngineRef, p.Spec.Mount, "pki")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"allowed_domains":    p.Spec.AllowedDomains,
+		"allow_subdomains":   p.Spec.AllowSubdomains,
+		"allow_bare_domains": p.Spec.AllowBareDomains,
+		"allow_glob_domains": p.Spec.AllowGlobDomains,
+		"allow_any_name":     p.Spec.AllowAnyName,
+		"ext_key_usage":      p.Spec.ExtKeyUsage,
+	}
+	// unset fields are left out so that vault applies its defaults
+	optional := map[string]string{
+		"key_type":   p.Spec.KeyType,
+		"ttl":        p.Spec.TTL,
+		"max_ttl":    p.Spec.MaxTTL,
+		"issuer_ref": p.Spec.IssuerRef,
+	}
+	for key, value := range optional {
+		if value != "" {
+			data[key] = value
+		}
+	}
+	if p.Spec.KeyBits != 0 {
+		data["key_bits"] = p.Spec.KeyBits
+	}
+	if p.Spec.EnforceHostnames != nil {
+		data["enforce_hostnames"] = *p.Spec.EnforceHostnames
+	}
+	if p.Spec.ServerFlag != nil {
+		data["server_flag"] = *p.Spec.ServerFlag
+	}
+	if p.Spec.ClientFlag != nil {
+		data["client_flag"] = *p.Spec.ClientFlag
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/roles/%s", path, p.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	effective, err := r.readEffective(path, p.Spec.Name)
+	if err != nil {
+		return err
+	}
+	hash, err := p.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.PKIRoleUpdatedState
+	if !p.IsCreated() {
+		state = apiv1.PKIRoleCreatedState
+	}
+	p.Status = &apiv1.PKIRoleStatus{
+		Hash:      hash,
+		State:     state,
+		Path:      path,
+		Effective: effective,
+	}
+	return r.Update(context.Background(), p)
+}
+
+// readEffective reads the role back from vault
+func (r *PKIRoleReconciler) readEffective(path, name string) (*apiv1.PKIRoleEffective, error) {
+	secret, err := r.APIClient.Logical().Read(fmt.Sprintf("%s/roles/%s", path, name))
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("role %s not found after writing it", name)
+	}
+	effective := &apiv1.PKIRoleEffective{
+		AllowedDomains: toStrings(secret.Data["allowed_domains"]),
+		ExtKeyUsage:    toStrings(secret.Data["ext_key_usage"]),
+	}
+	effective.AllowSubdomains, _ = secret.Data["allow_subdomains"].(bool)
+	effective.AllowBareDomains, _ = secret.Data["allow_bare_domains"].(bool)
+	effective.AllowGlobDomains, _ = secret.Data["allow_glob_domains"].(bool)
+	effective.AllowAnyName, _ = secret.Data["allow_any_name"].(bool)
+	effective.EnforceHostnames, _ = secret.Data["enforce_hostnames"].(bool)
+	effective.ServerFlag, _ = secret.Data["server_flag"].(bool)
+	effective.ClientFlag, _ = secret.Data["client_flag"].(bool)
+	effective.KeyType, _ = secret.Data["key_type"].(string)
+	effective.IssuerRef, _ = secret.Data["issuer_ref"].(string)
+	effective.KeyBits, _ = toInt64(secret.Data["key_bits"])
+	effective.TTL, _ = toInt64(secret.Data["ttl"])
+	effective.MaxTTL, _ = toInt64(secret.Data["max_ttl"])
+	return effective, nil
+}
+
+// IsUptoDate returns true if a pki role is current
+func (r *PKIRoleReconciler) IsUptoDate(p *apiv1.PKIRole) (bool, error) {
+	hash, err := p.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating pkirole hash: %v", err)
+	}
+	if p.Status == nil {
+		return false, nil
+	}
+	if p.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
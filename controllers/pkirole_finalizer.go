@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *PKIRoleReconciler) addFinalizer(instance *apiv1.PKIRole) error {
+	instance.AddFinalizer(apiv1.PKIRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *PKIRoleReconciler) handleFinalizer(p *apiv1.PKIRole) error {
+	if !p.HasFinalizer(apiv1.PKIRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(p); err != nil {
+		return err
+	}
+	p.RemoveFinalizer(apiv1.PKIRoleFinalizer)
+	return r.Update(context.Background(), p)
+}
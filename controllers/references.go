@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// getAuthMountPath returns the vault path of a created SysAuth of one of the expected types, any type is accepted when none is given
+func getAuthMountPath(c client.Client, namespace, name string, authTypes ...string) (string, error) {
+	sysauth := &apiv1.SysAuth{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, sysauth)
+	if err != nil {
+		return "", fmt.Errorf("error when getting sysauth %s: %v", name, err)
+	}
+	if !sysauth.IsCreated() {
+		return "", fmt.Errorf("sysauth %s is not created yet", name)
+	}
+	if len(authTypes) == 0 {
+		return sysauth.Spec.Path, nil
+	}
+	for _, authType := range authTypes {
+		if sysauth.Spec.Type == authType {
+			return sysauth.Spec.Path, nil
+		}
+	}
+	return "", fmt.Errorf("sysauth %s is of type %s, expected %s", name, sysauth.Spec.Type, strings.Join(authTypes, " or "))
+}
+
+// getSecretValue returns the value of a secret key along with the secret resource version
+func getSecretValue(c client.Client, namespace string, ref *apiv1.SecretKeyReference) (string, string, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret)
+	if err != nil {
+		return "", "", fmt.Errorf("error when getting secret %s: %v", ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", "", fmt.Errorf("key %s not found in secret %s", ref.Key, ref.Name)
+	}
+	return string(value), secret.ResourceVersion, nil
+}
+
+// resolvePolicies returns the vault policy names of the input policies and Policy objects,
+// together with the Policy objects which do not exist or are not created yet
+func resolvePolicies(c client.Client, namespace string, policies, refs []string) ([]string, []string, error) {
+	resolved := append([]string{}, policies...)
+	var missing []string
+	for _, ref := range refs {
+		policy := &apiv1.Policy{}
+		err := c.Get(context.TODO(), types.NamespacedName{Name: ref, Namespace: namespace}, policy)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				missing = append(missing, ref)
+				continue
+			}
+			return nil, nil, fmt.Errorf("error when getting policy %s: %v", ref, err)
+		}
+		if !policy.IsCreated() {
+			missing = append(missing, ref)
+			continue
+		}
+		resolved = append(resolved, policy.Spec.Name)
+	}
+	return resolved, missing, nil
+}
+
+// unmanagedPolicies returns the vault policy names which are neither created by a Policy object nor explicitly accepted
+func unmanagedPolicies(c client.Client, namespace string, names, accepted []string) ([]string, error) {
+	policies := &apiv1.PolicyList{}
+	if err := c.List(context.TODO(), policies, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("error when listing policies: %v", err)
+	}
+	managed := map[string]bool{}
+	for _, policy := range policies.Items {
+		if policy.Spec != nil && policy.IsCreated() {
+			managed[policy.Spec.Name] = true
+		}
+	}
+	var unmanaged []string
+	for _, name := range names {
+		if !managed[name] && !containsString(accepted, name) {
+			unmanaged = append(unmanaged, name)
+		}
+	}
+	return unmanaged, nil
+}
+
+// validateAuthMountType returns an error if the auth mount at path in vault is not of the expected type
+func validateAuthMountType(vclient *vaultapi.Client, path, authType string) error {
+	mounts, err := vclient.Sys().ListAuth()
+	if err != nil {
+		return fmt.Errorf("error when listing auth mounts: %v", err)
+	}
+	mount, ok := mounts[strings.Trim(path, "/")+"/"]
+	if !ok {
+		return fmt.Errorf("auth mount %s not found", path)
+	}
+	if mount.Type != authType {
+		return fmt.Errorf("auth mount %s is of type %s, expected %s", path, mount.Type, authType)
+	}
+	return nil
+}
+
+// getConfigMapValue returns the value of a configmap key along with the configmap resource version
+func getConfigMapValue(c client.Client, namespace, name, key string) (string, string, error) {
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, configMap)
+	if err != nil {
+		return "", "", fmt.Errorf("error when getting configmap %s: %v", name, err)
+	}
+	value, ok := configMap.Data[key]
+	if !ok {
+		return "", "", fmt.Errorf("key %s not found in configmap %s", key, name)
+	}
+	return value, configMap.ResourceVersion, nil
+}
+
+// resolveMountPath returns the mount path of a secrets engine, either from a created SecretsEngine of the expected type or a raw mount path
+func resolveMountPath(c client.Client, namespace, ref, mount, engineType string) (string, error) {
+	if ref == "" {
+		if mount == "" {
+			return "", fmt.Errorf("one of secretsengine_ref or mount is required")
+		}
+		return strings.Trim(mount, "/"), nil
+	}
+	engine := &apiv1.SecretsEngine{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: ref, Namespace: namespace}, engine)
+	if err != nil {
+		return "", fmt.Errorf("error when getting secretsengine %s: %v", ref, err)
+	}
+	if !engine.IsCreated() {
+		return "", fmt.Errorf("secretsengine %s is not created yet", ref)
+	}
+	if engine.Spec.Type != engineType {
+		return "", fmt.Errorf("secretsengine %s is of type %s, expected %s", ref, engine.Spec.Type, engineType)
+	}
+	return engine.Status.Path, nil
+}
+
+// getDatabaseConnection returns the mount path and connection name of a created DatabaseConnection
+func getDatabaseConnection(c client.Client, namespace, name string) (string, string, error) {
+	connection := &apiv1.DatabaseConnection{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, connection)
+	if err != nil {
+		return "", "", fmt.Errorf("error when getting databaseconnection %s: %v", name, err)
+	}
+	if !connection.IsCreated() || connection.Status.Path == "" {
+		return "", "", fmt.Errorf("databaseconnection %s is not created yet", name)
+	}
+	return connection.Status.Path, connection.Spec.Name, nil
+}
+
+// getAuthMountAccessor returns the vault accessor of the auth mount of a created SysAuth
+func getAuthMountAccessor(c client.Client, vclient *vaultapi.Client, namespace, name string) (string, error) {
+	path, err := getAuthMountPath(c, namespace, name)
+	if err != nil {
+		return "", err
+	}
+	mounts, err := vclient.Sys().ListAuth()
+	if err != nil {
+		return "", fmt.Errorf("error when listing auth mounts: %v", err)
+	}
+	mount, ok := mounts[strings.Trim(path, "/")+"/"]
+	if !ok {
+		return "", fmt.Errorf("auth mount %s not found", path)
+	}
+	return mount.Accessor, nil
+}
@@ -0,0 +1,444 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// defaultDriftCheckInterval is used when a secrets engine has no drift_check_interval
+const defaultDriftCheckInterval = 5 * time.Minute
+
+// SecretsEngineReconciler reconciles a SecretsEngine object
+type SecretsEngineReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// mountInput adds the mount fields missing from the vault api MountInput
+type mountInput struct {
+	*vaultapi.MountInput
+	ExternalEntropyAccess bool `json:"external_entropy_access"`
+}
+
+// mountOutput adds the mount fields missing from the vault api MountOutput
+type mountOutput struct {
+	vaultapi.MountOutput
+	ExternalEntropyAccess bool `json:"external_entropy_access"`
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *SecretsEngineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("secretsengine", req.NamespacedName)
+
+	engine := &apiv1.SecretsEngine{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, engine)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if engine.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(engine)
+		if err != nil {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(engine, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	interval := defaultDriftCheckInterval
+	if engine.Spec.DriftCheckInterval != "" {
+		interval, err = time.ParseDuration(engine.Spec.DriftCheckInterval)
+		if err != nil {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid drift_check_interval: %s", err))
+			return ctrl.Result{}, nil
+		}
+	}
+
+	if engine.IsCreated() && engine.Status.Path != mountPath(engine.Spec.Path) {
+		r.Log.Info(fmt.Sprintf("remounting secrets engine %v to %v", engine.Status.Path, engine.Spec.Path))
+		if err := r.remount(engine); err != nil {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to remount object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when remounting secretsengine: %v", err)
+		}
+		r.Recorder.Event(engine, corev1.EventTypeNormal, "updated", "secrets engine is remounted")
+		return ctrl.Result{}, nil
+	}
+
+	live, err := r.getMount(engine.Spec.Path)
+	if err != nil {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to read mount: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when reading secretsengine mount: %v", err)
+	}
+
+	if !engine.IsCreated() || live == nil {
+		if engine.IsCreated() {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "drifted", "secrets engine is not mounted anymore, mounting it again")
+		}
+		r.Log.Info(fmt.Sprintf("creating secrets engine %v", engine.Spec.Path))
+		if err := r.create(engine, live); err != nil {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating secretsengine: %v", err)
+		}
+
+		if !engine.HasFinalizer(apiv1.SecretsEngineFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(engine); err != nil {
+				r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(engine, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(engine, corev1.EventTypeNormal, "created", "secrets engine is created")
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+
+	if changes := immutableMountChanges(engine, live); len(changes) > 0 {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("%s can't be changed once mounted, recreate the object to apply", strings.Join(changes, ", ")))
+	}
+
+	isUptoDate, err := r.IsUptoDate(engine)
+	if err != nil {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking secretsengine IsUptoDate: %v", err)
+	}
+	drift, err := mountDrift(engine, live)
+	if err != nil {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check mount drift: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking secretsengine drift: %v", err)
+	}
+
+	if !isUptoDate || len(drift) > 0 {
+		if isUptoDate {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "drifted", fmt.Sprintf("secrets engine was changed outside of the controller: %s", strings.Join(drift, ", ")))
+		}
+		r.Log.Info(fmt.Sprintf("updating secrets engine %v", engine.Spec.Path))
+		if err := r.update(engine, live, drift); err != nil {
+			r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when updating secretsengine: %v", err)
+		}
+
+		if !engine.HasFinalizer(apiv1.SecretsEngineFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(engine); err != nil {
+				r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(engine, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(engine, corev1.EventTypeNormal, "updated", "secrets engine is updated")
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+
+	if wait := nextDriftCheck(engine, interval); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	if err := r.recordDriftCheck(engine); err != nil {
+		r.Recorder.Event(engine, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to record drift check: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when recording secretsengine drift check: %v", err)
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// nextDriftCheck returns the time left until the next drift check has to be recorded in status
+func nextDriftCheck(s *apiv1.SecretsEngine, interval time.Duration) time.Duration {
+	if s.Status == nil || s.Status.LastDriftCheck == nil {
+		return 0
+	}
+	return time.Until(s.Status.LastDriftCheck.Add(interval))
+}
+
+// recordDriftCheck records a drift check which found the mount matching the spec
+func (r *SecretsEngineReconciler) recordDriftCheck(s *apiv1.SecretsEngine) error {
+	now := metav1.Now()
+	s.Status.Drift = nil
+	s.Status.LastDriftCheck = &now
+	return r.Update(context.Background(), s)
+}
+
+func (r *SecretsEngineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.SecretsEngine{}).
+		Complete(r)
+}
+
+func (r *SecretsEngineReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// mountPath returns the path as stored in status, without leading or trailing slashes
+func mountPath(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// getMount returns the mount at path or nil when nothing is mounted there, it reads sys/mounts
+// directly as Sys().ListMounts drops external_entropy_access
+func (r *SecretsEngineReconciler) getMount(path string) (*mountOutput, error) {
+	mounts, err := r.APIClient.Logical().Read("sys/mounts")
+	if err != nil {
+		return nil, err
+	}
+	if mounts == nil {
+		return nil, fmt.Errorf("empty response listing mounts")
+	}
+	entry, ok := mounts.Data[mountPath(path)+"/"]
+	if !ok {
+		return nil, nil
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	live := &mountOutput{}
+	if err := json.Unmarshal(raw, live); err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+func (r *SecretsEngineReconciler) delete(s *apiv1.SecretsEngine) error {
+	if s.Status == nil || s.Status.Path == "" {
+		return nil
+	}
+	if s.IsRetained() {
+		r.Log.Info(fmt.Sprintf("retaining secrets engine %s", s.Status.Path))
+		return nil
+	}
+	r.Log.Info(fmt.Sprintf("deleting secrets engine %s", s.GetName()))
+	return r.APIClient.Sys().Unmount(s.Status.Path)
+}
+
+func (r *SecretsEngineReconciler) create(s *apiv1.SecretsEngine, live *mountOutput) error {
+	path := mountPath(s.Spec.Path)
+	if live != nil && live.Type != s.Spec.Type {
+		return fmt.Errorf("path %s is already mounted with type %s", path, live.Type)
+	}
+	if live == nil {
+		input := &vaultapi.MountInput{
+			Type:        s.Spec.Type,
+			Description: s.Spec.Description,
+			Local:       s.Spec.Local,
+			SealWrap:    s.Spec.SealWrap,
+			Options:     s.Spec.Options,
+			Config: vaultapi.MountConfigInput{
+				DefaultLeaseTTL: s.Spec.Config.DefaultLeaseTTL,
+				MaxLeaseTTL:     s.Spec.Config.MaxLeaseTTL,
+			},
+		}
+		if err := r.mount(path, input, s.Spec.ExternalEntropyAccess); err != nil {
+			return err
+		}
+		var err error
+		live, err = r.getMount(path)
+		if err != nil {
+			return err
+		}
+		if live == nil {
+			return fmt.Errorf("path %s is not mounted after mounting it", path)
+		}
+	}
+	hash, err := s.GetHash()
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	s.Status = &apiv1.SecretsEngineStatus{
+		Hash:           hash,
+		State:          apiv1.SecretsEngineCreatedState,
+		Path:           path,
+		Accessor:       live.Accessor,
+		LastDriftCheck: &now,
+	}
+	return r.Update(context.Background(), s)
+}
+
+// mount enables the engine, Sys().Mount can't send external_entropy_access so it is posted directly when set
+func (r *SecretsEngineReconciler) mount(path string, input *vaultapi.MountInput, externalEntropyAccess bool) error {
+	if !externalEntropyAccess {
+		return r.APIClient.Sys().Mount(path, input)
+	}
+	req := r.APIClient.NewRequest("POST", fmt.Sprintf("/v1/sys/mounts/%s", path))
+	if err := req.SetJSONBody(&mountInput{MountInput: input, ExternalEntropyAccess: true}); err != nil {
+		return err
+	}
+	resp, err := r.APIClient.RawRequest(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (r *SecretsEngineReconciler) remount(s *apiv1.SecretsEngine) error {
+	path := mountPath(s.Spec.Path)
+	if err := r.APIClient.Sys().Remount(s.Status.Path, path); err != nil {
+		return err
+	}
+	s.Status.Path = path
+	return r.Update(context.Background(), s)
+}
+
+func (r *SecretsEngineReconciler) update(s *apiv1.SecretsEngine, live *mountOutput, drift []string) error {
+	now := metav1.Now()
+	err := r.APIClient.Sys().TuneMount(mountPath(s.Spec.Path),
+		vaultapi.MountConfigInput{
+			Description:     &s.Spec.Description,
+			DefaultLeaseTTL: s.Spec.Config.DefaultLeaseTTL,
+			MaxLeaseTTL:     s.Spec.Config.MaxLeaseTTL,
+			Options:         s.Spec.Options,
+		})
+	if err != nil {
+		return err
+	}
+	hash, err := s.GetHash()
+	if err != nil {
+		return err
+	}
+	s.Status = &apiv1.SecretsEngineStatus{
+		Hash:           hash,
+		State:          apiv1.SecretsEngineUpdatedState,
+		Path:           mountPath(s.Spec.Path),
+		Accessor:       live.Accessor,
+		Drift:          drift,
+		LastDriftCheck: &now,
+	}
+	return r.Update(context.Background(), s)
+}
+
+// IsUptoDate returns true if a secrets engine is current
+func (r *SecretsEngineReconciler) IsUptoDate(s *apiv1.SecretsEngine) (bool, error) {
+	hash, err := s.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating secretsengine hash: %v", err)
+	}
+	if s.Status == nil {
+		return false, nil
+	}
+	if s.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
+
+// immutableMountChanges lists the spec settings differing from the mount which can't be tuned
+func immutableMountChanges(s *apiv1.SecretsEngine, live *mountOutput) []string {
+	var changes []string
+	if live.Type != s.Spec.Type {
+		changes = append(changes, "type")
+	}
+	if live.Local != s.Spec.Local {
+		changes = append(changes, "local")
+	}
+	if live.SealWrap != s.Spec.SealWrap {
+		changes = append(changes, "seal_wrap")
+	}
+	if live.ExternalEntropyAccess != s.Spec.ExternalEntropyAccess {
+		changes = append(changes, "external_entropy_access")
+	}
+	return changes
+}
+
+// mountDrift lists the tunable settings of the mount which differ from the spec
+func mountDrift(s *apiv1.SecretsEngine, live *mountOutput) ([]string, error) {
+	var drift []string
+	if live.Description != s.Spec.Description {
+		drift = append(drift, "description")
+	}
+	defaultLeaseTTL, err := durationSeconds(s.Spec.Config.DefaultLeaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default_lease_ttl: %v", err)
+	}
+	if live.Config.DefaultLeaseTTL != defaultLeaseTTL {
+		drift = append(drift, "default_lease_ttl")
+	}
+	maxLeaseTTL, err := durationSeconds(s.Spec.Config.MaxLeaseTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_lease_ttl: %v", err)
+	}
+	if live.Config.MaxLeaseTTL != maxLeaseTTL {
+		drift = append(drift, "max_lease_ttl")
+	}
+	keys := make([]string, 0, len(s.Spec.Options))
+	for key := range s.Spec.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if live.Options[key] != s.Spec.Options[key] {
+			drift = append(drift, "options."+key)
+		}
+	}
+	return drift, nil
+}
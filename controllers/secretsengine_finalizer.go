@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *SecretsEngineReconciler) addFinalizer(instance *apiv1.SecretsEngine) error {
+	instance.AddFinalizer(apiv1.SecretsEngineFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *SecretsEngineReconciler) handleFinalizer(s *apiv1.SecretsEngine) error {
+	if !s.HasFinalizer(apiv1.SecretsEngineFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(s); err != nil {
+		return err
+	}
+	s.RemoveFinalizer(apiv1.SecretsEngineFinalizer)
+	return r.Update(context.Background(), s)
+}
@@ -0,0 +1,315 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// SSHCAConfigReconciler reconciles a SSHCAConfig object
+type SSHCAConfigReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sshcaconfigs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sshcaconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *SSHCAConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("sshcaconfig", req.NamespacedName)
+
+	ca := &apiv1.SSHCAConfig{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, ca)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// the CA is kept in vault, the ConfigMap is garbage collected along with the object
+	if ca.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := ca.Spec.Validate(); err != nil {
+		r.Recorder.Event(ca, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(ca, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(ca, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	secrets, versions, err := r.getSecrets(ca)
+	if err != nil {
+		r.Recorder.Event(ca, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get keys: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting keys: %v", err)
+	}
+	secretsVersion := strings.Join(versions, ",")
+
+	isUptoDate, err := r.IsUptoDate(ca, secretsVersion)
+	if err != nil {
+		r.Recorder.Event(ca, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking sshcaconfig IsUptoDate: %v", err)
+	}
+
+	if !ca.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("configuring ssh ca %v", req.NamespacedName))
+		if err := r.put(ca, secrets, secretsVersion); err != nil {
+			r.Recorder.Event(ca, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing sshcaconfig: %v", err)
+		}
+		r.Recorder.Event(ca, corev1.EventTypeNormal, "updated", fmt.Sprintf("ca public key is published to configmap %s", ca.Status.ConfigMapName))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *SSHCAConfigReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *SSHCAConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.SSHCAConfig{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretToConfigs),
+		}).
+		Complete(r)
+}
+
+// secretToConfigs enqueues the configs importing a CA key held by a Secret
+func (r *SSHCAConfigReconciler) secretToConfigs(o handler.MapObject) []reconcile.Request {
+	configs := &apiv1.SSHCAConfigList{}
+	if err := r.List(context.Background(), configs, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list ssh ca configs")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, config := range configs.Items {
+		if config.Spec == nil {
+			continue
+		}
+		for _, ref := range []*apiv1.SecretKeyReference{config.Spec.PrivateKey, config.Spec.PublicKey} {
+			if ref != nil && ref.Name == o.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: config.GetName(), Namespace: config.GetNamespace()},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// getSecrets returns the imported keys keyed by vault parameter, along with the secret resource versions
+func (r *SSHCAConfigReconciler) getSecrets(s *apiv1.SSHCAConfig) (map[string]string, []string, error) {
+	values := map[string]string{}
+	var versions []string
+	if s.Spec.PrivateKey != nil {
+		value, version, err := getSecretValue(r.Client, s.GetNamespace(), s.Spec.PrivateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["private_key"] = value
+		versions = append(versions, version)
+	}
+	if s.Spec.PublicKey != nil {
+		value, version, err := getSecretValue(r.Client, s.GetNamespace(), s.Spec.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		values["public_key"] = value
+		versions = append(versions, version)
+	}
+	return values, versions, nil
+}
+
+// readPublicKey returns the public key of the CA of a ssh mount, empty if none is configured yet
+func (r *SSHCAConfigReconciler) readPublicKey(path string) (string, error) {
+	secret, err := r.APIClient.Logical().Read(fmt.Sprintf("%s/config/ca", path))
+	if err != nil {
+		// vault answers with a bad request while no keys are configured
+		if respErr, ok := err.(*vaultapi.ResponseError); ok && respErr.StatusCode == http.StatusBadRequest {
+			return "", nil
+		}
+		return "", fmt.Errorf("error when reading ssh ca of %s: %v", path, err)
+	}
+	if secret == nil {
+		return "", nil
+	}
+	publicKey, _ := secret.Data["public_key"].(string)
+	return publicKey, nil
+}
+
+func (r *SSHCAConfigReconciler) put(s *apiv1.SSHCAConfig, secrets map[string]string, secretsVersion string) error {
+	path, err := resolveMountPath(r.Client, s.GetNamespace(), s.Spec.SecretsEngineRef, s.Spec.Mount, "ssh")
+	if err != nil {
+		return err
+	}
+	publicKey, err := r.readPublicKey(path)
+	if err != nil {
+		return err
+	}
+
+	imported := len(secrets) > 0
+	switch {
+	case imported && (publicKey == "" || !s.IsCreated() || s.Status.SecretsVersion != secretsVersion):
+		if publicKey != "" && strings.TrimSpace(publicKey) == strings.TrimSpace(secrets["public_key"]) {
+			break
+		}
+		if publicKey != "" && !s.IsCreated() && !s.Spec.Force {
+			return fmt.Errorf("%s already holds a different ssh ca, set force to replace it", path)
+		}
+		if publicKey != "" {
+			// vault refuses to overwrite configured keys
+			if _, err := r.APIClient.Logical().Delete(fmt.Sprintf("%s/config/ca", path)); err != nil {
+				return fmt.Errorf("error when deleting ssh ca: %v", err)
+			}
+		}
+		data := map[string]interface{}{
+			"generate_signing_key": false,
+		}
+		for key, value := range secrets {
+			data[key] = value
+		}
+		if _, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/config/ca", path), data); err != nil {
+			return fmt.Errorf("error when importing ssh ca: %v", err)
+		}
+		publicKey = secrets["public_key"]
+		r.Recorder.Event(s, corev1.EventTypeNormal, "created", fmt.Sprintf("ssh ca is imported in %s", path))
+	case !imported && publicKey == "":
+		data := map[string]interface{}{
+			"generate_signing_key": true,
+		}
+		if s.Spec.KeyType != "" {
+			data["key_type"] = s.Spec.KeyType
+		}
+		if s.Spec.KeyBits != 0 {
+			data["key_bits"] = s.Spec.KeyBits
+		}
+		secret, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/config/ca", path), data)
+		if err != nil {
+			return fmt.Errorf("error when generating ssh ca: %v", err)
+		}
+		if secret != nil {
+			publicKey, _ = secret.Data["public_key"].(string)
+		}
+		if publicKey == "" {
+			if publicKey, err = r.readPublicKey(path); err != nil {
+				return err
+			}
+		}
+		r.Recorder.Event(s, corev1.EventTypeNormal, "created", fmt.Sprintf("ssh ca is generated in %s", path))
+	}
+
+	err = publishConfigMap(r.Client, r.Scheme, s, s.GetConfigMapName(), map[string]string{
+		apiv1.SSHCAConfigPublicKeyKey: strings.TrimSpace(publicKey) + "\n",
+	})
+	if err != nil {
+		return fmt.Errorf("error when publishing ca public key: %v", err)
+	}
+
+	hash, err := s.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.SSHCAConfigUpdatedState
+	if !s.IsCreated() {
+		state = apiv1.SSHCAConfigCreatedState
+	}
+	s.Status = &apiv1.SSHCAConfigStatus{
+		Hash:           hash,
+		State:          state,
+		Path:           path,
+		SecretsVersion: secretsVersion,
+		ConfigMapName:  s.GetConfigMapName(),
+	}
+	return r.Update(context.Background(), s)
+}
+
+// IsUptoDate returns true if the CA was configured from the current spec and keys and its public key is published
+func (r *SSHCAConfigReconciler) IsUptoDate(s *apiv1.SSHCAConfig, secretsVersion string) (bool, error) {
+	hash, err := s.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating sshcaconfig hash: %v", err)
+	}
+	if s.Status == nil || s.Status.Hash != hash || s.Status.SecretsVersion != secretsVersion {
+		return false, nil
+	}
+	configMap := &corev1.ConfigMap{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: s.Status.ConfigMapName, Namespace: s.GetNamespace()}, configMap)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
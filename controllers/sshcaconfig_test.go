@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func TestSSHCAConfigImportReplacesExistingCA(t *testing.T) {
+	tests := []struct {
+		name     string
+		force    bool
+		replaced bool
+	}{
+		{name: "without force", force: false, replaced: false},
+		{name: "with force", force: true, replaced: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := newFakeVault()
+			defer vault.Close()
+			vault.set("ssh/config/ca", map[string]interface{}{"public_key": "ssh-ed25519 AAAAexisting"})
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: apiv1.WatchNamespace},
+				Data: map[string][]byte{
+					"private_key": []byte("private"),
+					"public_key":  []byte("ssh-ed25519 AAAAimported"),
+				},
+			}
+			caConfig := &apiv1.SSHCAConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: apiv1.WatchNamespace, UID: "ca"},
+				Spec: &apiv1.SSHCAConfigSpec{
+					SecretsEngineRef: "ssh",
+					PrivateKey:       &apiv1.SecretKeyReference{Name: "ca", Key: "private_key"},
+					PublicKey:        &apiv1.SecretKeyReference{Name: "ca", Key: "public_key"},
+					Force:            tt.force,
+				},
+			}
+			c := newFakeClient(t, vault, newCreatedSecretsEngine("ssh", "ssh"), secret, caConfig)
+			r := &SSHCAConfigReconciler{Client: c, Log: ctrl.Log, Scheme: newScheme(t), Recorder: record.NewFakeRecorder(10)}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "ca", Namespace: apiv1.WatchNamespace}}
+
+			_, err := r.Reconcile(req)
+			if tt.replaced && err != nil {
+				t.Fatalf("unexpected reconcile error: %v", err)
+			}
+			if !tt.replaced && err == nil {
+				t.Fatal("expected an error replacing an existing ca without force")
+			}
+			if vault.deleted("ssh/config/ca") != tt.replaced {
+				t.Errorf("expected the existing ca to be replaced: %v", tt.replaced)
+			}
+			data, _ := vault.get("ssh/config/ca")
+			if tt.replaced && data["public_key"] != "ssh-ed25519 AAAAimported" {
+				t.Errorf("expected the imported ca to be written, got %v", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *SSHRoleReconciler) addFinalizer(instance *apiv1.SSHRole) error {
+	instance.AddFinalizer(apiv1.SSHRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *SSHRoleReconciler) handleFinalizer(s *apiv1.SSHRole) error {
+	if !s.HasFinalizer(apiv1.SSHRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(s); err != nil {
+		return err
+	}
+	s.RemoveFinalizer(apiv1.SSHRoleFinalizer)
+	return r.Update(context.Background(), s)
+}
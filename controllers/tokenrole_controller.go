@@ -0,0 +1,244 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// TokenRoleReconciler reconciles a TokenRole object
+type TokenRoleReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=tokenroles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=tokenroles/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=policies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *TokenRoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("tokenrole", req.NamespacedName)
+
+	role := &apiv1.TokenRole{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, role)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if role.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(role)
+		if err != nil {
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	unmanaged, err := unmanagedPolicies(r.Client, role.GetNamespace(), role.Spec.AllowedPolicies, role.Spec.UnmanagedPolicies)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to validate allowed policies: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when validating allowed policies: %v", err)
+	}
+	if len(unmanaged) > 0 {
+		// The role is written once the policies are created or accepted in unmanaged_policies
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("allowed policies are not managed: %s", strings.Join(unmanaged, ", ")))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(role)
+	if err != nil {
+		r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking tokenrole IsUptoDate: %v", err)
+	}
+
+	if !role.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating token role %v", role.Spec.Name))
+		if err := r.put(role); err != nil {
+			if !role.IsCreated() {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating tokenrole: %v", err)
+		}
+
+		if !role.HasFinalizer(apiv1.TokenRoleFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(role); err != nil {
+				r.Recorder.Event(role, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(role, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(role, corev1.EventTypeNormal, "updated", "token role is updated")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *TokenRoleReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *TokenRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.TokenRole{}).
+		Watches(&source.Kind{Type: &apiv1.Policy{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.policyToRoles),
+		}).
+		Complete(r)
+}
+
+// policyToRoles enqueues the token roles allowing a Policy so they are written once it is created
+func (r *TokenRoleReconciler) policyToRoles(o handler.MapObject) []reconcile.Request {
+	policy, ok := o.Object.(*apiv1.Policy)
+	if !ok || policy.Spec == nil {
+		return nil
+	}
+	roles := &apiv1.TokenRoleList{}
+	if err := r.List(context.Background(), roles, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list token roles")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, role := range roles.Items {
+		if role.Spec == nil {
+			continue
+		}
+		for _, name := range role.Spec.AllowedPolicies {
+			if name == policy.Spec.Name {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: role.GetName(), Namespace: role.GetNamespace()},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+func (r *TokenRoleReconciler) delete(t *apiv1.TokenRole) error {
+	r.Log.Info(fmt.Sprintf("deleting token role %s", t.GetName()))
+	if t.Status == nil {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/token/roles/%s", t.Spec.Name))
+	return err
+}
+
+func (r *TokenRoleReconciler) put(t *apiv1.TokenRole) error {
+	data := map[string]interface{}{
+		"allowed_policies":       t.Spec.AllowedPolicies,
+		"disallowed_policies":    t.Spec.DisallowedPolicies,
+		"orphan":                 t.Spec.Orphan,
+		"token_period":           t.Spec.TokenPeriod,
+		"token_bound_cidrs":      t.Spec.TokenBoundCIDRs,
+		"token_explicit_max_ttl": t.Spec.TokenExplicitMaxTTL,
+		"path_suffix":            t.Spec.PathSuffix,
+	}
+	if t.Spec.Renewable != nil {
+		data["renewable"] = *t.Spec.Renewable
+	}
+	_, err := r.APIClient.Logical().Write(fmt.Sprintf("auth/token/roles/%s", t.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := t.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.TokenRoleUpdatedState
+	if !t.IsCreated() {
+		state = apiv1.TokenRoleCreatedState
+	}
+	t.Status = &apiv1.TokenRoleStatus{
+		Hash:  hash,
+		State: state,
+	}
+	return r.Update(context.Background(), t)
+}
+
+// IsUptoDate returns true if a token role is current
+func (r *TokenRoleReconciler) IsUptoDate(t *apiv1.TokenRole) (bool, error) {
+	hash, err := t.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating tokenrole hash: %v", err)
+	}
+	if t.Status == nil {
+		return false, nil
+	}
+	if t.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
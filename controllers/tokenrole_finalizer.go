@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *TokenRoleReconciler) addFinalizer(instance *apiv1.TokenRole) error {
+	instance.AddFinalizer(apiv1.TokenRoleFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *TokenRoleReconciler) handleFinalizer(t *apiv1.TokenRole) error {
+	if !t.HasFinalizer(apiv1.TokenRoleFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(t); err != nil {
+		return err
+	}
+	t.RemoveFinalizer(apiv1.TokenRoleFinalizer)
+	return r.Update(context.Background(), t)
+}
@@ -0,0 +1,300 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// TransitKeyReconciler reconciles a TransitKey object
+type TransitKeyReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=transitkeys,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=transitkeys/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *TransitKeyReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("transitkey", req.NamespacedName)
+
+	key := &apiv1.TransitKey{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, key)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if key.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(key)
+		if err != nil {
+			r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(key, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	var schedule *cronSchedule
+	if key.Spec.RotationSchedule != "" {
+		if schedule, err = parseCron(key.Spec.RotationSchedule); err != nil {
+			r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+			return ctrl.Result{}, nil
+		}
+	}
+
+	isUptoDate, err := r.IsUptoDate(key)
+	if err != nil {
+		r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking transitkey IsUptoDate: %v", err)
+	}
+
+	if !key.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating transit key %v", key.Spec.Name))
+		if err := r.put(key, schedule); err != nil {
+			if !key.IsCreated() {
+				r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating transitkey: %v", err)
+		}
+
+		if !key.HasFinalizer(apiv1.TransitKeyFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(key); err != nil {
+				r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(key, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(key, corev1.EventTypeNormal, "updated", "transit key is updated")
+	}
+
+	if schedule == nil || key.Status.NextRotation == nil {
+		return ctrl.Result{}, nil
+	}
+	if time.Now().Before(key.Status.NextRotation.Time) {
+		return ctrl.Result{RequeueAfter: time.Until(key.Status.NextRotation.Time)}, nil
+	}
+	r.Log.Info(fmt.Sprintf("rotating transit key %v", key.Spec.Name))
+	if err := r.rotate(key, schedule); err != nil {
+		r.Recorder.Event(key, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to rotate key: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when rotating transitkey: %v", err)
+	}
+	r.Recorder.Event(key, corev1.EventTypeNormal, "rotated", fmt.Sprintf("transit key is rotated to version %d", key.Status.LatestVersion))
+	return ctrl.Result{RequeueAfter: time.Until(key.Status.NextRotation.Time)}, nil
+}
+
+func (r *TransitKeyReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *TransitKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.TransitKey{}).
+		Complete(r)
+}
+
+func (r *TransitKeyReconciler) delete(t *apiv1.TransitKey) error {
+	if t.Status == nil || t.Status.Path == "" {
+		return nil
+	}
+	if t.IsRetained() {
+		r.Log.Info(fmt.Sprintf("retaining transit key %s", t.Spec.Name))
+		return nil
+	}
+	if !t.Spec.DeletionAllowed {
+		r.Recorder.Event(t, corev1.EventTypeWarning, "failed", fmt.Sprintf("transit key %s is kept in vault as deletion_allowed is false", t.Spec.Name))
+		return nil
+	}
+	r.Log.Info(fmt.Sprintf("deleting transit key %s", t.Spec.Name))
+	_, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/keys/%s/config", t.Status.Path, t.Spec.Name), map[string]interface{}{
+		"deletion_allowed": true,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.APIClient.Logical().Delete(fmt.Sprintf("%s/keys/%s", t.Status.Path, t.Spec.Name))
+	return err
+}
+
+func (r *TransitKeyReconciler) put(t *apiv1.TransitKey, schedule *cronSchedule) error {
+	path, err := resolveMountPath(r.Client, t.GetNamespace(), t.Spec.SecretsEngineRef, t.Spec.Mount, "transit")
+	if err != nil {
+		return err
+	}
+	keyPath := fmt.Sprintf("%s/keys/%s", path, t.Spec.Name)
+
+	live, err := r.APIClient.Logical().Read(keyPath)
+	if err != nil {
+		return fmt.Errorf("error when reading transit key: %v", err)
+	}
+	if live == nil {
+		data := map[string]interface{}{
+			"exportable":             t.Spec.Exportable,
+			"allow_plaintext_backup": t.Spec.AllowPlaintextBackup,
+		}
+		if t.Spec.Type != "" {
+			data["type"] = t.Spec.Type
+		}
+		if _, err := r.APIClient.Logical().Write(keyPath, data); err != nil {
+			return fmt.Errorf("error when creating transit key: %v", err)
+		}
+		r.Recorder.Event(t, corev1.EventTypeNormal, "created", fmt.Sprintf("transit key %s is created", t.Spec.Name))
+	} else if liveType, _ := live.Data["type"].(string); t.Spec.Type != "" && liveType != t.Spec.Type {
+		r.Recorder.Event(t, corev1.EventTypeWarning, "failed", fmt.Sprintf("type can't be changed from %s once the key is created", liveType))
+	}
+
+	data := map[string]interface{}{
+		"deletion_allowed":       t.Spec.DeletionAllowed,
+		"exportable":             t.Spec.Exportable,
+		"allow_plaintext_backup": t.Spec.AllowPlaintextBackup,
+	}
+	if t.Spec.MinDecryptionVersion != 0 {
+		data["min_decryption_version"] = t.Spec.MinDecryptionVersion
+	}
+	if t.Spec.MinEncryptionVersion != 0 {
+		data["min_encryption_version"] = t.Spec.MinEncryptionVersion
+	}
+	// auto_rotate_period is always sent so that removing it from the spec disables the rotation in vault
+	data["auto_rotate_period"] = "0"
+	if t.Spec.AutoRotatePeriod != "" {
+		data["auto_rotate_period"] = t.Spec.AutoRotatePeriod
+	}
+	if _, err := r.APIClient.Logical().Write(keyPath+"/config", data); err != nil {
+		return fmt.Errorf("error when configuring transit key: %v", err)
+	}
+
+	version, err := r.latestVersion(keyPath)
+	if err != nil {
+		return err
+	}
+	hash, err := t.GetHash()
+	if err != nil {
+		return err
+	}
+	status := &apiv1.TransitKeyStatus{
+		Hash:          hash,
+		State:         apiv1.TransitKeyCreatedState,
+		Path:          path,
+		LatestVersion: version,
+	}
+	if t.IsCreated() {
+		status.State = apiv1.TransitKeyUpdatedState
+		status.LastRotated = t.Status.LastRotated
+	}
+	if schedule != nil {
+		next := metav1.NewTime(schedule.Next(time.Now().UTC()))
+		status.NextRotation = &next
+	}
+	t.Status = status
+	return r.Update(context.Background(), t)
+}
+
+func (r *TransitKeyReconciler) rotate(t *apiv1.TransitKey, schedule *cronSchedule) error {
+	keyPath := fmt.Sprintf("%s/keys/%s", t.Status.Path, t.Spec.Name)
+	if _, err := r.APIClient.Logical().Write(keyPath+"/rotate", nil); err != nil {
+		return err
+	}
+	version, err := r.latestVersion(keyPath)
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	next := metav1.NewTime(schedule.Next(now.UTC()))
+	t.Status.LatestVersion = version
+	t.Status.LastRotated = &now
+	t.Status.NextRotation = &next
+	return r.Update(context.Background(), t)
+}
+
+// latestVersion returns the latest version of a transit key
+func (r *TransitKeyReconciler) latestVersion(keyPath string) (int64, error) {
+	secret, err := r.APIClient.Logical().Read(keyPath)
+	if err != nil {
+		return 0, fmt.Errorf("error when reading transit key: %v", err)
+	}
+	if secret == nil {
+		return 0, fmt.Errorf("transit key %s not found", keyPath)
+	}
+	return toInt64(secret.Data["latest_version"])
+}
+
+// IsUptoDate returns true if the key was configured from the current spec
+func (r *TransitKeyReconciler) IsUptoDate(t *apiv1.TransitKey) (bool, error) {
+	hash, err := t.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating transitkey hash: %v", err)
+	}
+	if t.Status == nil || t.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
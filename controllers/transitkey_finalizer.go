@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *TransitKeyReconciler) addFinalizer(instance *apiv1.TransitKey) error {
+	instance.AddFinalizer(apiv1.TransitKeyFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *TransitKeyReconciler) handleFinalizer(t *apiv1.TransitKey) error {
+	if !t.HasFinalizer(apiv1.TransitKeyFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(t); err != nil {
+		return err
+	}
+	t.RemoveFinalizer(apiv1.TransitKeyFinalizer)
+	return r.Update(context.Background(), t)
+}
@@ -0,0 +1,346 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+const (
+	transitBackupKey        = "backup"
+	transitBackupVersionKey = "key_version"
+)
+
+// TransitKeyBackupReconciler reconciles a TransitKeyBackup object
+type TransitKeyBackupReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=transitkeybackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=transitkeybackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *TransitKeyBackupReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("transitkeybackup", req.NamespacedName)
+
+	backup := &apiv1.TransitKeyBackup{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, backup)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// backup Secrets are not owned by the object so they outlive it
+	if backup.IsBeingDeleted() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := backup.Spec.Validate(); err != nil {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+	var schedule *cronSchedule
+	if backup.Spec.Schedule != "" && !backup.IsRestore() {
+		if schedule, err = parseCron(backup.Spec.Schedule); err != nil {
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// Initializing vault config
+	configName := "config"
+	if backup.IsRestore() && backup.Spec.TargetConfig != "" {
+		configName = backup.Spec.TargetConfig
+	}
+	config, err := r.getConfig(configName)
+	if err != nil {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(backup)
+	if err != nil {
+		r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking transitkeybackup IsUptoDate: %v", err)
+	}
+
+	if backup.IsRestore() {
+		if backup.IsCreated() && isUptoDate {
+			return ctrl.Result{}, nil
+		}
+		r.Log.Info(fmt.Sprintf("restoring transit key %v", backup.Spec.Name))
+		if err := r.restore(backup); err != nil {
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to restore key: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when restoring transitkeybackup: %v", err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "restored", fmt.Sprintf("transit key is restored from %s", backup.Status.RestoredFrom))
+		return ctrl.Result{}, nil
+	}
+
+	due := !backup.IsCreated() || !isUptoDate
+	if schedule != nil && backup.Status != nil && backup.Status.NextBackup != nil && !time.Now().Before(backup.Status.NextBackup.Time) {
+		due = true
+	}
+	if due {
+		r.Log.Info(fmt.Sprintf("backing up transit key %v", backup.Spec.Name))
+		if err := r.backup(backup, schedule); err != nil {
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to back up key: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when backing up transitkeybackup: %v", err)
+		}
+		r.Recorder.Event(backup, corev1.EventTypeNormal, "created", fmt.Sprintf("transit key version %d is backed up to %s", backup.Status.KeyVersion, backup.Status.Secrets[len(backup.Status.Secrets)-1]))
+	}
+
+	if backup.Status.NextBackup == nil {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: time.Until(backup.Status.NextBackup.Time)}, nil
+}
+
+func (r *TransitKeyBackupReconciler) getConfig(name string) (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      name,
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *TransitKeyBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.TransitKeyBackup{}).
+		Complete(r)
+}
+
+func (r *TransitKeyBackupReconciler) backup(t *apiv1.TransitKeyBackup, schedule *cronSchedule) error {
+	path, err := resolveMountPath(r.Client, t.GetNamespace(), t.Spec.SecretsEngineRef, t.Spec.Mount, "transit")
+	if err != nil {
+		return err
+	}
+	key, err := r.APIClient.Logical().Read(fmt.Sprintf("%s/keys/%s", path, t.Spec.Name))
+	if err != nil {
+		return fmt.Errorf("error when reading transit key: %v", err)
+	}
+	if key == nil {
+		return fmt.Errorf("transit key %s not found in %s", t.Spec.Name, path)
+	}
+	version, err := toInt64(key.Data["latest_version"])
+	if err != nil {
+		return err
+	}
+	secret, err := r.APIClient.Logical().Read(fmt.Sprintf("%s/backup/%s", path, t.Spec.Name))
+	if err != nil {
+		return fmt.Errorf("error when backing up transit key: %v", err)
+	}
+	if secret == nil {
+		return fmt.Errorf("empty response when backing up transit key")
+	}
+	blob, _ := secret.Data["backup"].(string)
+
+	now := metav1.Now()
+	// millisecond precision keeps the names ordered, the random suffix avoids collisions between objects
+	// sharing a secret_name
+	name := fmt.Sprintf("%s-%s%03d-%s", t.GetSecretName(), now.UTC().Format("20060102150405"),
+		now.Nanosecond()/int(time.Millisecond), utilrand.String(5))
+	created := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.GetNamespace(),
+			Labels: map[string]string{
+				apiv1.TransitKeyBackupLabel:      t.GetSecretName(),
+				apiv1.TransitKeyBackupOwnerLabel: t.GetName(),
+			},
+		},
+		Data: map[string][]byte{
+			transitBackupKey:        []byte(blob),
+			transitBackupVersionKey: []byte(strconv.FormatInt(version, 10)),
+		},
+	}
+	if err := r.Create(context.Background(), created); err != nil {
+		return fmt.Errorf("error when creating backup secret %s: %v", name, err)
+	}
+
+	secrets, err := r.listBackups(t, true)
+	if err != nil {
+		return err
+	}
+	// the cache may not hold the Secret just created yet
+	listed := false
+	for _, s := range secrets {
+		listed = listed || s.GetName() == name
+	}
+	if !listed {
+		secrets = append(secrets, *created)
+	}
+	for len(secrets) > t.GetGenerations() {
+		if err := r.Delete(context.Background(), &secrets[0]); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("error when pruning backup secret %s: %v", secrets[0].GetName(), err)
+		}
+		secrets = secrets[1:]
+	}
+	var names []string
+	for _, s := range secrets {
+		names = append(names, s.GetName())
+	}
+
+	hash, err := t.GetHash()
+	if err != nil {
+		return err
+	}
+	t.Status = &apiv1.TransitKeyBackupStatus{
+		Hash:       hash,
+		State:      apiv1.TransitKeyBackupCreatedState,
+		Path:       path,
+		LastBackup: &now,
+		KeyVersion: version,
+		Secrets:    names,
+	}
+	if schedule != nil {
+		next := metav1.NewTime(schedule.Next(now.UTC()))
+		t.Status.NextBackup = &next
+	}
+	return r.Update(context.Background(), t)
+}
+
+// listBackups returns the backup Secrets of the secret_name prefix, oldest first, restricted to the
+// ones created by the object when owned is set
+func (r *TransitKeyBackupReconciler) listBackups(t *apiv1.TransitKeyBackup, owned bool) ([]corev1.Secret, error) {
+	selector := client.MatchingLabels{
+		apiv1.TransitKeyBackupLabel: t.GetSecretName(),
+	}
+	if owned {
+		selector[apiv1.TransitKeyBackupOwnerLabel] = t.GetName()
+	}
+	secrets := &corev1.SecretList{}
+	err := r.List(context.Background(), secrets, client.InNamespace(t.GetNamespace()), selector)
+	if err != nil {
+		return nil, fmt.Errorf("error when listing backup secrets: %v", err)
+	}
+	items := secrets.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].CreationTimestamp.Equal(&items[j].CreationTimestamp) {
+			return items[i].GetName() < items[j].GetName()
+		}
+		return items[i].CreationTimestamp.Before(&items[j].CreationTimestamp)
+	})
+	return items, nil
+}
+
+func (r *TransitKeyBackupReconciler) restore(t *apiv1.TransitKeyBackup) error {
+	path, err := resolveMountPath(r.Client, t.GetNamespace(), t.Spec.SecretsEngineRef, t.Spec.Mount, "transit")
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{}
+	if t.Spec.RestoreFrom != "" {
+		err := r.Get(context.Background(), types.NamespacedName{Name: t.Spec.RestoreFrom, Namespace: t.GetNamespace()}, secret)
+		if err != nil {
+			return fmt.Errorf("error when getting backup secret %s: %v", t.Spec.RestoreFrom, err)
+		}
+	} else {
+		secrets, err := r.listBackups(t, false)
+		if err != nil {
+			return err
+		}
+		if len(secrets) == 0 {
+			return fmt.Errorf("no backup secret found for %s", t.GetSecretName())
+		}
+		secret = &secrets[len(secrets)-1]
+	}
+	blob, ok := secret.Data[transitBackupKey]
+	if !ok {
+		return fmt.Errorf("key %s not found in secret %s", transitBackupKey, secret.GetName())
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("%s/restore/%s", path, t.Spec.Name), map[string]interface{}{
+		"backup": string(blob),
+		"force":  t.Spec.Force,
+	})
+	if err != nil {
+		return err
+	}
+	version, _ := strconv.ParseInt(string(secret.Data[transitBackupVersionKey]), 10, 64)
+
+	hash, err := t.GetHash()
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	t.Status = &apiv1.TransitKeyBackupStatus{
+		Hash:         hash,
+		State:        apiv1.TransitKeyBackupRestoredState,
+		Path:         path,
+		KeyVersion:   version,
+		RestoredFrom: secret.GetName(),
+		LastRestore:  &now,
+	}
+	return r.Update(context.Background(), t)
+}
+
+// IsUptoDate returns true if the last backup or restore was run from the current spec
+func (r *TransitKeyBackupReconciler) IsUptoDate(t *apiv1.TransitKeyBackup) (bool, error) {
+	hash, err := t.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating transitkeybackup hash: %v", err)
+	}
+	if t.Status == nil || t.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,251 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// UserpassUserReconciler reconciles a UserpassUser object
+type UserpassUserReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=userpassusers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=userpassusers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=sysauths,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *UserpassUserReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("userpassuser", req.NamespacedName)
+
+	user := &apiv1.UserpassUser{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, user)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	if user.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(user)
+		if err != nil {
+			r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(user, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	// The password is only ever passed to vault, it must not end up in logs or events
+	password, passwordVersion, err := getSecretValue(r.Client, user.GetNamespace(), &user.Spec.Password)
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get password: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when getting password: %v", err)
+	}
+
+	isUptoDate, err := r.IsUptoDate(user)
+	if err != nil {
+		r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking userpassuser IsUptoDate: %v", err)
+	}
+
+	if !user.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("creating/updating userpass user %v", user.Spec.Name))
+		if err := r.put(user, password, passwordVersion); err != nil {
+			if !user.IsCreated() {
+				r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to create object: %s", err))
+			}
+			r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update object: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when creating userpassuser: %v", err)
+		}
+
+		if !user.HasFinalizer(apiv1.UserpassUserFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(user); err != nil {
+				r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(user, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(user, corev1.EventTypeNormal, "updated", "userpass user is updated")
+		return ctrl.Result{}, nil
+	}
+
+	if user.Status.PasswordVersion != passwordVersion {
+		r.Log.Info(fmt.Sprintf("updating password of userpass user %v", user.Spec.Name))
+		if err := r.updatePassword(user, password, passwordVersion); err != nil {
+			r.Recorder.Event(user, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to update password from secret %s: %s", user.Spec.Password.Name, err))
+			return ctrl.Result{}, fmt.Errorf("error when updating userpassuser password: %v", err)
+		}
+		r.Recorder.Event(user, corev1.EventTypeNormal, "updated", fmt.Sprintf("password is updated from secret %s", user.Spec.Password.Name))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *UserpassUserReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *UserpassUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.UserpassUser{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretToUsers),
+		}).
+		Complete(r)
+}
+
+// secretToUsers enqueues the users whose password is held by a Secret
+func (r *UserpassUserReconciler) secretToUsers(o handler.MapObject) []reconcile.Request {
+	users := &apiv1.UserpassUserList{}
+	if err := r.List(context.Background(), users, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list userpass users")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, user := range users.Items {
+		if user.Spec != nil && user.Spec.Password.Name == o.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: user.GetName(), Namespace: user.GetNamespace()},
+			})
+		}
+	}
+	return requests
+}
+
+func (r *UserpassUserReconciler) delete(u *apiv1.UserpassUser) error {
+	r.Log.Info(fmt.Sprintf("deleting userpass user %s", u.GetName()))
+	if u.Status == nil || u.Status.Path == "" {
+		return nil
+	}
+	_, err := r.APIClient.Logical().Delete(fmt.Sprintf("auth/%s/users/%s", u.Status.Path, u.Spec.Name))
+	return err
+}
+
+func (r *UserpassUserReconciler) put(u *apiv1.UserpassUser, password, passwordVersion string) error {
+	path, err := getAuthMountPath(r.Client, u.GetNamespace(), u.Spec.SysAuthRef, "userpass")
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"password":       password,
+		"token_policies": u.Spec.TokenPolicies,
+		"token_ttl":      u.Spec.TokenTTL,
+		"token_max_ttl":  u.Spec.TokenMaxTTL,
+	}
+	_, err = r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/users/%s", path, u.Spec.Name), data)
+	if err != nil {
+		return err
+	}
+	hash, err := u.GetHash()
+	if err != nil {
+		return err
+	}
+	state := apiv1.UserpassUserUpdatedState
+	if !u.IsCreated() {
+		state = apiv1.UserpassUserCreatedState
+	}
+	u.Status = &apiv1.UserpassUserStatus{
+		Hash:            hash,
+		State:           state,
+		Path:            path,
+		PasswordVersion: passwordVersion,
+	}
+	return r.Update(context.Background(), u)
+}
+
+func (r *UserpassUserReconciler) updatePassword(u *apiv1.UserpassUser, password, passwordVersion string) error {
+	_, err := r.APIClient.Logical().Write(fmt.Sprintf("auth/%s/users/%s/password", u.Status.Path, u.Spec.Name),
+		map[string]interface{}{
+			"password": password,
+		})
+	if err != nil {
+		return err
+	}
+	u.Status.PasswordVersion = passwordVersion
+	return r.Update(context.Background(), u)
+}
+
+// IsUptoDate returns true if a userpass user is current
+func (r *UserpassUserReconciler) IsUptoDate(u *apiv1.UserpassUser) (bool, error) {
+	hash, err := u.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating userpassuser hash: %v", err)
+	}
+	if u.Status == nil {
+		return false, nil
+	}
+	if u.Status.Hash != hash {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *UserpassUserReconciler) addFinalizer(instance *apiv1.UserpassUser) error {
+	instance.AddFinalizer(apiv1.UserpassUserFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *UserpassUserReconciler) handleFinalizer(u *apiv1.UserpassUser) error {
+	if !u.HasFinalizer(apiv1.UserpassUserFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(u); err != nil {
+		return err
+	}
+	u.RemoveFinalizer(apiv1.UserpassUserFinalizer)
+	return r.Update(context.Background(), u)
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// equalStrings returns true if both slices hold the same items in the same order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString returns true if the slice holds the input
+func containsString(slice []string, input string) bool {
+	for _, item := range slice {
+		if item == input {
+			return true
+		}
+	}
+	return false
+}
+
+// toInt64 converts a numeric value of a vault response
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Int64()
+	case float64:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("unexpected numeric value %v", value)
+}
+
+// durationSeconds converts a vault duration, either a go duration or a number of seconds, to seconds
+func durationSeconds(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return int(duration.Seconds()), nil
+}
+
+// certificatesNotAfter returns the earliest expiry of the certificates of a PEM bundle
+func certificatesNotAfter(bundle string) (time.Time, error) {
+	var notAfter time.Time
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error when parsing certificate: %v", err)
+		}
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+	if notAfter.IsZero() {
+		return time.Time{}, fmt.Errorf("no certificate found in PEM data")
+	}
+	return notAfter, nil
+}
+
+// toStrings converts a list of a vault response
+func toStrings(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var result []string
+	for _, item := range items {
+		result = append(result, fmt.Sprintf("%v", item))
+	}
+	return result
+}
+
+// parseCertificate returns the first certificate of PEM data
+func parseCertificate(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in PEM data")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certificateSerial formats the serial number of a certificate the way vault does
+func certificateSerial(cert *x509.Certificate) string {
+	var parts []string
+	for _, b := range cert.SerialNumber.Bytes() {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+	return strings.Join(parts, ":")
+}
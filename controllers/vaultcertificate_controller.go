@@ -0,0 +1,273 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// VaultCertificateReconciler reconciles a VaultCertificate object
+type VaultCertificateReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=vaultcertificates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=vaultcertificates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=secretsengines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *VaultCertificateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("vaultcertificate", req.NamespacedName)
+
+	certificate := &apiv1.VaultCertificate{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, certificate)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			certificateExpiryGauge.DeleteLabelValues(req.Namespace, req.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// the Secret is garbage collected along with the object
+	if certificate.IsBeingDeleted() {
+		certificateExpiryGauge.DeleteLabelValues(req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if err := certificate.Spec.Validate(); err != nil {
+		r.Recorder.Event(certificate, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(certificate, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(certificate, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(certificate)
+	if err != nil {
+		r.Recorder.Event(certificate, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking vaultcertificate IsUptoDate: %v", err)
+	}
+
+	if !certificate.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("issuing certificate %v", certificate.Spec.CommonName))
+		if err := r.issue(certificate); err != nil {
+			r.Recorder.Event(certificate, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to issue certificate: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when issuing vaultcertificate: %v", err)
+		}
+		r.Recorder.Event(certificate, corev1.EventTypeNormal, "updated", fmt.Sprintf("certificate %s is written to secret %s", certificate.Status.SerialNumber, certificate.Status.SecretName))
+	}
+
+	certificateExpiryGauge.WithLabelValues(certificate.GetNamespace(), certificate.GetName()).Set(float64(certificate.Status.NotAfter.Unix()))
+	return ctrl.Result{RequeueAfter: time.Until(certificate.Status.RenewalTime.Time)}, nil
+}
+
+func (r *VaultCertificateReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *VaultCertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.VaultCertificate{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+func (r *VaultCertificateReconciler) issue(v *apiv1.VaultCertificate) error {
+	path, err := resolveMountPath(r.Client, v.GetNamespace(), v.Spec.SecretsEngineRef, v.Spec.Mount, "pki")
+	if err != nil {
+		return err
+	}
+	if err := r.checkSecret(v); err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"common_name": v.Spec.CommonName,
+		"alt_names":   strings.Join(v.Spec.AltNames, ","),
+		"ip_sans":     strings.Join(v.Spec.IPSANs, ","),
+		"uri_sans":    strings.Join(v.Spec.URISANs, ","),
+		"ttl":         v.Spec.TTL,
+	}
+	secret, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/issue/%s", path, v.Spec.Role), data)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return fmt.Errorf("empty response when issuing certificate")
+	}
+	cert, _ := secret.Data["certificate"].(string)
+	key, _ := secret.Data["private_key"].(string)
+	issuingCA, _ := secret.Data["issuing_ca"].(string)
+	serial, _ := secret.Data["serial_number"].(string)
+	chain := append([]string{cert}, toStrings(secret.Data["ca_chain"])...)
+
+	parsed, err := parseCertificate(cert)
+	if err != nil {
+		return err
+	}
+	if err := r.writeSecret(v, strings.Join(chain, "\n"), key, issuingCA); err != nil {
+		return err
+	}
+
+	previous := ""
+	state := apiv1.VaultCertificateIssuedState
+	if v.IsCreated() {
+		previous = v.Status.SerialNumber
+		state = apiv1.VaultCertificateRenewedState
+	}
+	hash, err := v.GetHash()
+	if err != nil {
+		return err
+	}
+	percent := v.Spec.RenewAtPercent
+	if percent == 0 {
+		percent = apiv1.VaultCertificateDefaultRenewAtPercent
+	}
+	lifetime := parsed.NotAfter.Sub(parsed.NotBefore)
+	notAfter := metav1.NewTime(parsed.NotAfter)
+	renewalTime := metav1.NewTime(parsed.NotBefore.Add(lifetime * time.Duration(percent) / 100))
+	v.Status = &apiv1.VaultCertificateStatus{
+		Hash:         hash,
+		State:        state,
+		Path:         path,
+		SecretName:   v.GetSecretName(),
+		SerialNumber: serial,
+		NotAfter:     &notAfter,
+		RenewalTime:  &renewalTime,
+	}
+	if err := r.Update(context.Background(), v); err != nil {
+		return err
+	}
+
+	if v.Spec.RevokePrevious && previous != "" && previous != serial {
+		_, err := r.APIClient.Logical().Write(fmt.Sprintf("%s/revoke", path), map[string]interface{}{
+			"serial_number": previous,
+		})
+		if err != nil {
+			r.Recorder.Event(v, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to revoke previous certificate %s: %s", previous, err))
+		}
+	}
+	return nil
+}
+
+// checkSecret returns an error when the target Secret exists but cannot hold the certificate,
+// it is checked before issuing so that no certificate is issued for nothing
+func (r *VaultCertificateReconciler) checkSecret(v *apiv1.VaultCertificate) error {
+	secret := &corev1.Secret{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: v.GetSecretName(), Namespace: v.GetNamespace()}, secret)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(secret, v) {
+		return fmt.Errorf("%s already exists and is not owned by %s, refusing to overwrite it", secret.GetName(), v.GetName())
+	}
+	if secret.Type != corev1.SecretTypeTLS {
+		return fmt.Errorf("secret %s exists with type %s", secret.GetName(), secret.Type)
+	}
+	return nil
+}
+
+func (r *VaultCertificateReconciler) writeSecret(v *apiv1.VaultCertificate, chain, key, ca string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      v.GetSecretName(),
+			Namespace: v.GetNamespace(),
+		},
+	}
+	return writeOwned(r.Client, r.Scheme, v, secret, func() {
+		secret.Type = corev1.SecretTypeTLS
+		secret.Data = map[string][]byte{
+			corev1.TLSCertKey:       []byte(chain),
+			corev1.TLSPrivateKeyKey: []byte(key),
+			"ca.crt":                []byte(ca),
+		}
+	})
+}
+
+// IsUptoDate returns true if the Secret holds a certificate for the current spec which is not due for renewal
+func (r *VaultCertificateReconciler) IsUptoDate(v *apiv1.VaultCertificate) (bool, error) {
+	hash, err := v.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating vaultcertificate hash: %v", err)
+	}
+	if v.Status == nil || v.Status.RenewalTime == nil || v.Status.NotAfter == nil {
+		return false, nil
+	}
+	if v.Status.Hash != hash || !time.Now().Before(v.Status.RenewalTime.Time) {
+		return false, nil
+	}
+	secret := &corev1.Secret{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: v.Status.SecretName, Namespace: v.GetNamespace()}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,326 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/mitchellh/hashstructure"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+// defaultRefreshInterval is used when a vault secret has no refresh_interval
+const defaultRefreshInterval = 5 * time.Minute
+
+// VaultSecretReconciler reconciles a VaultSecret object
+type VaultSecretReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIClient *vaultapi.Client
+	Recorder  record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=vaultsecrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vault.gobins.github.io,resources=vaultsecrets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create
+
+func (r *VaultSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("vaultsecret", req.NamespacedName)
+
+	vaultSecret := &apiv1.VaultSecret{}
+	log.Info(fmt.Sprintf("starting reconcile loop for %v", req.NamespacedName))
+	defer log.Info(fmt.Sprintf("completed reconcile loop for %v", req.NamespacedName))
+	err := r.Get(ctx, req.NamespacedName, vaultSecret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if vaultSecret.IsBeingDeleted() {
+		log.Info("run finalizer")
+		err := r.handleFinalizer(vaultSecret)
+		if err != nil {
+			r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to delete finalizer: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when handling finalizer: %v", err)
+		}
+		r.Recorder.Event(vaultSecret, corev1.EventTypeNormal, "deleted", "object finalizer is deleted")
+		return ctrl.Result{}, nil
+	}
+
+	if err := vaultSecret.Spec.Validate(); err != nil {
+		r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("invalid spec: %s", err))
+		return ctrl.Result{}, nil
+	}
+	interval := defaultRefreshInterval
+	if vaultSecret.Spec.RefreshInterval != "" {
+		interval, _ = time.ParseDuration(vaultSecret.Spec.RefreshInterval)
+	}
+
+	// Initializing vault config
+	config, err := r.getConfig()
+	if err != nil {
+		r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to get vault config: %s", err))
+		return ctrl.Result{}, nil
+	}
+	if config != nil {
+		address := config.Data["address"]
+		token := config.Data["token"]
+		r.APIClient, err = GetClient(address, token)
+	}
+	if err != nil {
+		r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to init vault client: %s", err))
+		return ctrl.Result{}, nil
+	}
+
+	kvVersion := vaultSecret.Spec.KVVersion
+	if kvVersion == 0 {
+		kvVersion = 2
+	}
+	kvData, version, err := readKV(r.APIClient, vaultSecret.Spec.Mount, vaultSecret.Spec.Path, kvVersion, vaultSecret.Spec.Version)
+	if err != nil {
+		r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to read kv secret: %s", err))
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+	data, err := renderSecretData(vaultSecret.Spec.Keys, kvData)
+	if err != nil {
+		r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to render secret data: %s", err))
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+
+	isUptoDate, err := r.IsUptoDate(vaultSecret, data, version)
+	if err != nil {
+		r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to check object upto date: %s", err))
+		return ctrl.Result{}, fmt.Errorf("error when checking vaultsecret IsUptoDate: %v", err)
+	}
+
+	if !vaultSecret.IsCreated() || !isUptoDate {
+		r.Log.Info(fmt.Sprintf("writing secret %v", vaultSecret.GetSecretName()))
+		if err := r.put(vaultSecret, data, version); err != nil {
+			r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to write secret: %s", err))
+			return ctrl.Result{}, fmt.Errorf("error when writing vaultsecret: %v", err)
+		}
+
+		if !vaultSecret.HasFinalizer(apiv1.VaultSecretFinalizer) {
+			r.Log.Info(fmt.Sprintf("add finalizer for %v", req.NamespacedName))
+			if err := r.addFinalizer(vaultSecret); err != nil {
+				r.Recorder.Event(vaultSecret, corev1.EventTypeWarning, "failed", fmt.Sprintf("failed to add finalizer: %s", err))
+				return ctrl.Result{}, fmt.Errorf("error when adding finalizer: %v", err)
+			}
+			r.Recorder.Event(vaultSecret, corev1.EventTypeNormal, "added", "object finalizer is added")
+		}
+		r.Recorder.Event(vaultSecret, corev1.EventTypeNormal, "updated", fmt.Sprintf("secret %s is written", vaultSecret.GetSecretName()))
+	}
+
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+func (r *VaultSecretReconciler) getConfig() (*corev1.ConfigMap, error) {
+	config := &corev1.ConfigMap{}
+	err := r.Client.Get(
+		context.TODO(),
+		types.NamespacedName{
+			Name:      "config",
+			Namespace: apiv1.WatchNamespace,
+		},
+		config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (r *VaultSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.VaultSecret{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+// renderSecretData returns the Secret data for the KV data, all KV keys are copied when no keys are given
+func renderSecretData(keys []apiv1.VaultSecretKey, kvData map[string]interface{}) (map[string]string, error) {
+	data := map[string]string{}
+	if len(keys) == 0 {
+		for key, value := range kvData {
+			s, err := kvString(value)
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding key %s: %v", key, err)
+			}
+			data[key] = s
+		}
+		return data, nil
+	}
+	for _, key := range keys {
+		if key.Template != "" {
+			s, err := renderTemplate(key.Name, key.Template, kvData)
+			if err != nil {
+				return nil, fmt.Errorf("error when rendering key %s: %v", key.Name, err)
+			}
+			data[key.Name] = s
+			continue
+		}
+		value, ok := kvData[key.From]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in kv secret", key.From)
+		}
+		s, err := kvString(value)
+		if err != nil {
+			return nil, fmt.Errorf("error when encoding key %s: %v", key.From, err)
+		}
+		data[key.Name] = s
+	}
+	return data, nil
+}
+
+// getSecret returns the Secret with the given name or nil when it doesn't exist
+func (r *VaultSecretReconciler) getSecret(v *apiv1.VaultSecret, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: v.GetNamespace()}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (r *VaultSecretReconciler) delete(v *apiv1.VaultSecret) error {
+	if v.Status == nil || v.Status.SecretName == "" {
+		return nil
+	}
+	secret, err := r.getSecret(v, v.Status.SecretName)
+	if err != nil || secret == nil || !metav1.IsControlledBy(secret, v) {
+		return err
+	}
+	if v.Spec.DeletionPolicy == apiv1.VaultSecretDeletionPolicyOrphan {
+		r.Log.Info(fmt.Sprintf("orphaning secret %s", secret.GetName()))
+		var refs []metav1.OwnerReference
+		for _, ref := range secret.GetOwnerReferences() {
+			if ref.UID != v.GetUID() {
+				refs = append(refs, ref)
+			}
+		}
+		secret.SetOwnerReferences(refs)
+		return r.Update(context.Background(), secret)
+	}
+	r.Log.Info(fmt.Sprintf("deleting secret %s", secret.GetName()))
+	if err := r.Delete(context.Background(), secret); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *VaultSecretReconciler) put(v *apiv1.VaultSecret, data map[string]string, version int64) error {
+	name := v.GetSecretName()
+	if v.Status != nil && v.Status.SecretName != "" && v.Status.SecretName != name {
+		// the Secret was renamed, the previous one is removed according to the deletion policy
+		if err := r.delete(v); err != nil {
+			return err
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: v.GetNamespace(),
+		},
+	}
+	err := writeOwned(r.Client, r.Scheme, v, secret, func() {
+		secret.Data = map[string][]byte{}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	hash, err := v.GetHash()
+	if err != nil {
+		return err
+	}
+	dataHash, err := hashstructure.Hash(data, nil)
+	if err != nil {
+		return err
+	}
+	state := apiv1.VaultSecretUpdatedState
+	if !v.IsCreated() {
+		state = apiv1.VaultSecretCreatedState
+	}
+	now := metav1.Now()
+	v.Status = &apiv1.VaultSecretStatus{
+		Hash:       hash,
+		State:      state,
+		SecretName: name,
+		Version:    version,
+		DataHash:   fmt.Sprintf("%d", dataHash),
+		LastSynced: &now,
+	}
+	return r.Update(context.Background(), v)
+}
+
+// IsUptoDate returns true if the Secret holds the current KV data
+func (r *VaultSecretReconciler) IsUptoDate(v *apiv1.VaultSecret, data map[string]string, version int64) (bool, error) {
+	hash, err := v.GetHash()
+	if err != nil {
+		return false, fmt.Errorf("error when calculating vaultsecret hash: %v", err)
+	}
+	if v.Status == nil {
+		return false, nil
+	}
+	dataHash, err := hashstructure.Hash(data, nil)
+	if err != nil {
+		return false, fmt.Errorf("error when calculating vaultsecret data hash: %v", err)
+	}
+	if v.Status.Hash != hash || v.Status.Version != version || v.Status.DataHash != fmt.Sprintf("%d", dataHash) {
+		return false, nil
+	}
+
+	// the Secret is written again when it was deleted or edited
+	secret, err := r.getSecret(v, v.Status.SecretName)
+	if err != nil {
+		return false, err
+	}
+	if secret == nil || len(secret.Data) != len(data) {
+		return false, nil
+	}
+	for key, value := range data {
+		if string(secret.Data[key]) != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+
+	apiv1 "github.com/gobins/vault-controller/api/v1"
+)
+
+func (r *VaultSecretReconciler) addFinalizer(instance *apiv1.VaultSecret) error {
+	instance.AddFinalizer(apiv1.VaultSecretFinalizer)
+	return r.Update(context.Background(), instance)
+}
+
+func (r *VaultSecretReconciler) handleFinalizer(v *apiv1.VaultSecret) error {
+	if !v.HasFinalizer(apiv1.VaultSecretFinalizer) {
+		return nil
+	}
+
+	if err := r.delete(v); err != nil {
+		return err
+	}
+	v.RemoveFinalizer(apiv1.VaultSecretFinalizer)
+	return r.Update(context.Background(), v)
+}